@@ -0,0 +1,133 @@
+package main
+
+import (
+	"unicode/utf8"
+)
+
+// maxHeldEscape bounds how much of an unterminated string sequence (OSC etc)
+// is held back waiting for its terminator, past that it is passed on as is.
+const maxHeldEscape = 4096
+
+type tokenKind int
+
+const (
+	// tokenText is a run of complete runes, invalid UTF-8 included byte by byte
+	tokenText tokenKind = iota
+	// tokenControl is a single C0 control byte or DEL, other than ESC
+	tokenControl
+	// tokenEscape is a complete escape sequence, starting with the ESC
+	tokenEscape
+)
+
+// ansiScanner splits terminal output, written in arbitrary chunks, into text,
+// control characters and escape sequences.
+//
+// Anything cut off at the end of a chunk (half a UTF-8 rune, or an escape
+// sequence missing its final byte) is held back until the next chunk arrives,
+// so filters built on it never see, or split, a partial sequence.
+type ansiScanner struct {
+	held []byte
+}
+
+// scan calls fn with each complete token in held back input followed by p. The
+// token is only valid until fn returns.
+func (s *ansiScanner) scan(p []byte, fn func(kind tokenKind, tok []byte)) {
+	buf := p
+	if len(s.held) > 0 {
+		buf = append(s.held, p...)
+		s.held = s.held[:0]
+	}
+	for len(buf) > 0 {
+		kind, n := nextToken(buf)
+		if n == 0 {
+			// incomplete, wait for more
+			s.held = append(s.held, buf...)
+			return
+		}
+		fn(kind, buf[:n])
+		buf = buf[n:]
+	}
+}
+
+// flush passes on anything held back, however incomplete, e.g. when the stream
+// ends.
+func (s *ansiScanner) flush(fn func(kind tokenKind, tok []byte)) {
+	if len(s.held) > 0 {
+		fn(tokenText, s.held)
+		s.held = s.held[:0]
+	}
+}
+
+// nextToken returns the kind and length of the token at the start of buf, or
+// zero length if buf ends before the token does.
+func nextToken(buf []byte) (tokenKind, int) {
+	switch c := buf[0]; {
+	case c == 0x1b:
+		return tokenEscape, escapeLength(buf)
+	case c < 0x20 || c == 0x7f:
+		return tokenControl, 1
+	}
+	i := 0
+	for i < len(buf) {
+		c := buf[i]
+		if c < 0x20 || c == 0x7f {
+			break
+		}
+		if c < utf8.RuneSelf {
+			i++
+			continue
+		}
+		if !utf8.FullRune(buf[i:]) {
+			break
+		}
+		_, size := utf8.DecodeRune(buf[i:])
+		i += size
+	}
+	return tokenText, i
+}
+
+// escapeLength returns the length of the escape sequence at the start of buf,
+// or zero if it is incomplete.
+func escapeLength(buf []byte) int {
+	if len(buf) < 2 {
+		return 0
+	}
+	switch buf[1] {
+	case '[':
+		// CSI: parameter and intermediate bytes, then a final byte
+		for i := 2; i < len(buf); i++ {
+			if c := buf[i]; c >= 0x40 && c <= 0x7e {
+				return i + 1
+			} else if c < 0x20 || c > 0x7e {
+				// malformed, don't swallow what follows
+				return i
+			}
+		}
+	case ']', 'P', '_', '^', 'X':
+		// OSC, DCS, APC, PM and SOS strings, terminated by BEL or ST (ESC \)
+		for i := 2; i < len(buf); i++ {
+			if buf[i] == 0x07 {
+				return i + 1
+			}
+			if buf[i] == 0x1b && i+1 < len(buf) && buf[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		if len(buf) > maxHeldEscape {
+			return len(buf)
+		}
+	default:
+		// intermediate bytes, e.g. ESC ( B, then a final byte
+		for i := 1; i < len(buf); i++ {
+			switch c := buf[i]; {
+			case c >= 0x20 && c <= 0x2f:
+				continue
+			case c >= 0x30 && c <= 0x7e:
+				return i + 1
+			default:
+				return i
+			}
+		}
+	}
+	return 0
+}
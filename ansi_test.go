@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"testing"
+	"unicode/utf8"
+)
+
+// splitSample has an escape sequence of each kind and multi-byte runes, for
+// splitting at every offset.
+const splitSample = "plain \x1b[1;31mred\x1b[0m é日本🙂\x1b]0;title\x1b\\ \x1b]2;bel\x07\x1b(B\ttab\r\n"
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// scanChunks scans chunks in turn, returning the tokens it gives.
+func scanChunks(chunks ...[]byte) []token {
+	var s ansiScanner
+	var toks []token
+	collect := func(kind tokenKind, tok []byte) {
+		toks = append(toks, token{kind, string(tok)})
+	}
+	for _, c := range chunks {
+		s.scan(c, collect)
+	}
+	s.flush(collect)
+	return toks
+}
+
+// joinText merges adjacent text tokens, as where a chunk ends doesn't matter
+// to how text is split up.
+func joinText(toks []token) []token {
+	var joined []token
+	for _, t := range toks {
+		if n := len(joined); n > 0 && t.kind == tokenText && joined[n-1].kind == tokenText {
+			joined[n-1].text += t.text
+			continue
+		}
+		joined = append(joined, t)
+	}
+	return joined
+}
+
+func TestAnsiScannerSplit(t *testing.T) {
+	in := []byte(splitSample)
+	want := joinText(scanChunks(in))
+	var escapes []string
+	for _, tok := range want {
+		if tok.kind == tokenEscape {
+			escapes = append(escapes, tok.text)
+		}
+	}
+	if wantEscapes := []string{"\x1b[1;31m", "\x1b[0m", "\x1b]0;title\x1b\\", "\x1b]2;bel\x07", "\x1b(B"}; !slices.Equal(escapes, wantEscapes) {
+		t.Fatalf("escapes %q, want %q", escapes, wantEscapes)
+	}
+	for i := range len(in) + 1 {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			toks := scanChunks(bytes.Clone(in[:i]), bytes.Clone(in[i:]))
+			for _, tok := range toks {
+				if tok.kind == tokenText && !utf8.ValidString(tok.text) {
+					t.Errorf("text token %q splits a rune", tok.text)
+				}
+			}
+			if got := joinText(toks); !slices.Equal(got, want) {
+				t.Errorf("split at %d gives %q, want %q", i, got, want)
+			}
+		})
+	}
+}
+
+func TestAnsiScannerByteAtATime(t *testing.T) {
+	in := []byte(splitSample)
+	chunks := make([][]byte, len(in))
+	for i := range in {
+		chunks[i] = in[i : i+1]
+	}
+	if got, want := joinText(scanChunks(chunks...)), joinText(scanChunks(in)); !slices.Equal(got, want) {
+		t.Errorf("byte at a time gives %q, want %q", got, want)
+	}
+}
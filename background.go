@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// backgroundBuffer is how much output is held back while the terminal can't
+// be seen before it is passed on to it anyway, so memory stays bounded however
+// much a run writes.
+const backgroundBuffer = 1 << 20
+
+// pausableWriter holds back output while paused, passing it on in one go
+// once resumed (or once backgroundBuffer fills). The terminal redraws for
+// every write it reads, which is most of the cost of a busy run, and is
+// wasted while nobody is looking at it.
+type pausableWriter struct {
+	w      io.Writer
+	paused atomic.Bool
+
+	// mu orders the writes, and guards buf
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (p *pausableWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused.Load() {
+		p.buf = append(p.buf, b...)
+		if len(p.buf) < backgroundBuffer {
+			return len(b), nil
+		}
+		return len(b), p.flushLocked()
+	}
+	if err := p.flushLocked(); err != nil {
+		return 0, err
+	}
+	return p.w.Write(b)
+}
+
+// Flush passes on anything held back.
+func (p *pausableWriter) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.flushLocked()
+}
+
+func (p *pausableWriter) flushLocked() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	_, err := p.w.Write(p.buf)
+	p.buf = p.buf[:0]
+	return err
+}
+
+func (p *pausableWriter) setPaused(paused bool) {
+	p.paused.Store(paused)
+	if !paused {
+		// the write blocks until the terminal has read it, and this is
+		// called from the UI goroutine
+		go func() { _ = p.Flush() }()
+	}
+}
+
+// pauseGroup pauses the terminal output of a session's current run while it
+// can't be seen.
+type pauseGroup struct {
+	mu      sync.Mutex
+	paused  bool
+	writers []*pausableWriter
+}
+
+// wrap returns a writer for w that is paused along with the group.
+func (g *pauseGroup) wrap(w io.Writer) *pausableWriter {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	p := &pausableWriter{w: w}
+	p.paused.Store(g.paused)
+	g.writers = append(g.writers, p)
+	return p
+}
+
+func (g *pauseGroup) setPaused(paused bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.paused = paused
+	for _, p := range g.writers {
+		p.setPaused(paused)
+	}
+}
+
+// release flushes and forgets the writers of a run that has finished.
+func (g *pauseGroup) release(done []*pausableWriter) {
+	g.mu.Lock()
+	g.writers = slices.DeleteFunc(g.writers, func(p *pausableWriter) bool {
+		return slices.Contains(done, p)
+	})
+	g.mu.Unlock()
+	for _, p := range done {
+		_ = p.Flush()
+	}
+}
+
+// pauseUnseen pauses the output of every session that can't be seen, as the
+// main window is minimised or its tab isn't the selected one. A window that
+// is only unfocused is still seen, so it carries on. It must be called from
+// the UI goroutine.
+func (s *AppState) pauseUnseen() {
+	selected := s.tabs.Selected()
+	for _, sess := range s.sessions {
+		sess.background.setPaused(s.minimised || sess.tab != selected)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	"fyne.io/fyne/v2"
+)
+
+const (
+	// prefBacklogMode is what is done with output the terminal hasn't caught
+	// up with, one of the backlog* modes.
+	prefBacklogMode = "output.backlogMode"
+	// prefBacklogLines is how many lines may wait for the terminal before
+	// any are skipped.
+	prefBacklogLines     = "output.backlogLines"
+	defaultBacklogLines  = 10000
+	minBacklogLines      = 100
+	backlogMaxLineBytes  = 1 << 20
+	backlogSkippedFormat = "\r\033[0m[%d lines skipped, the terminal fell behind, they are all in the scrollback]\r\n"
+)
+
+const (
+	// backlogWait holds the container back until the terminal catches up,
+	// as a full pipe would.
+	backlogWait = "wait"
+	// backlogSummarize skips the oldest lines waiting, saying how many.
+	backlogSummarize = "summarize"
+	// backlogDrop skips them without saying.
+	backlogDrop = "drop"
+)
+
+// backlogModes are the choices of prefBacklogMode, in the order offered.
+var backlogModes = []struct{ mode, label string }{
+	{backlogWait, "Wait for it"},
+	{backlogSummarize, "Skip lines, saying how many"},
+	{backlogDrop, "Skip lines"},
+}
+
+// backlogSettings are the configured mode and number of lines.
+func backlogSettings(prefs fyne.Preferences) (string, int) {
+	mode := prefs.StringWithFallback(prefBacklogMode, backlogWait)
+	if mode != backlogSummarize && mode != backlogDrop {
+		mode = backlogWait
+	}
+	return mode, max(prefs.IntWithFallback(prefBacklogLines, defaultBacklogLines), minBacklogLines)
+}
+
+// backlogWriter passes writes on to the terminal from a goroutine of its own,
+// so a container with a lot of output isn't held up while the terminal draws
+// it. Once more than maxLines are waiting the oldest are skipped, noting how
+// many if summarize is set. Only the terminal misses them, the scrollback and
+// transcript are written to separately.
+type backlogWriter struct {
+	w         io.Writer
+	maxLines  int
+	summarize bool
+
+	// mu guards the rest, cond is signalled as any of it changes
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []byte
+	// lines are the line feeds in pending
+	lines   int
+	skipped int
+	closed  bool
+	// err is from the last write to w, returned by the next Write
+	err  error
+	done chan struct{}
+}
+
+func newBacklogWriter(w io.Writer, maxLines int, summarize bool) *backlogWriter {
+	b := &backlogWriter{w: w, maxLines: maxLines, summarize: summarize, done: make(chan struct{})}
+	b.cond = sync.NewCond(&b.mu)
+	go b.run()
+	return b
+}
+
+func (b *backlogWriter) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return 0, b.err
+	}
+	b.pending = append(b.pending, p...)
+	b.lines += bytes.Count(p, []byte{'\n'})
+	b.skip()
+	b.cond.Broadcast()
+	return len(p), nil
+}
+
+// skip drops the oldest lines pending past maxLines. They are cut at line
+// feeds, so what is left starts on a line of its own, unless one line is so
+// long that it has to go too.
+func (b *backlogWriter) skip() {
+	if b.lines > b.maxLines {
+		cut := 0
+		for range b.lines - b.maxLines {
+			cut += bytes.IndexByte(b.pending[cut:], '\n') + 1
+		}
+		b.skipped += b.lines - b.maxLines
+		b.lines = b.maxLines
+		b.pending = b.pending[:copy(b.pending, b.pending[cut:])]
+	}
+	if b.lines == 0 && len(b.pending) > backlogMaxLineBytes {
+		b.skipped++
+		b.pending = b.pending[:0]
+	}
+}
+
+func (b *backlogWriter) run() {
+	defer close(b.done)
+	var buf []byte
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for {
+		for len(b.pending) == 0 && b.skipped == 0 && !b.closed {
+			b.cond.Wait()
+		}
+		if len(b.pending) == 0 && b.skipped == 0 {
+			return
+		}
+		buf = buf[:0]
+		if b.summarize && b.skipped > 0 {
+			buf = fmt.Appendf(buf, backlogSkippedFormat, b.skipped)
+		}
+		buf = append(buf, b.pending...)
+		b.pending, b.lines, b.skipped = b.pending[:0], 0, 0
+		b.mu.Unlock()
+		_, err := b.w.Write(buf)
+		b.mu.Lock()
+		if err != nil && b.err == nil {
+			b.err = err
+		}
+		b.cond.Broadcast()
+		if b.err != nil {
+			return
+		}
+	}
+}
+
+// Close passes on whatever is still waiting and stops the goroutine, waiting
+// for it to finish.
+func (b *backlogWriter) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.cond.Broadcast()
+	b.mu.Unlock()
+	<-b.done
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+const (
+	// prefOutputBatch is how long, in milliseconds, output is gathered before
+	// it is passed on to the terminal, 0 not to.
+	prefOutputBatch     = "output.batchMillis"
+	defaultOutputBatch  = 8
+	maxOutputBatch      = 1000
+	outputBatchMaxBytes = 64 << 10
+)
+
+// outputBatch is the configured time to gather output for.
+func outputBatch(prefs fyne.Preferences) time.Duration {
+	ms := min(max(prefs.IntWithFallback(prefOutputBatch, defaultOutputBatch), 0), maxOutputBatch)
+	return time.Duration(ms) * time.Millisecond
+}
+
+// batchWriter gathers up writes for interval, or until outputBatchMaxBytes,
+// passing them on in one. The terminal redraws for every write it reads, so a
+// container writing in many small pieces costs far more than the same output
+// in a few big ones.
+type batchWriter struct {
+	w        io.Writer
+	interval time.Duration
+
+	// mu orders the writes, and guards the rest
+	mu    sync.Mutex
+	buf   []byte
+	timer *time.Timer
+	// err is from a write done by the timer, returned by the next Write
+	err error
+}
+
+func newBatchWriter(w io.Writer, interval time.Duration) *batchWriter {
+	b := &batchWriter{w: w, interval: interval}
+	b.timer = time.AfterFunc(interval, func() { _ = b.Flush() })
+	b.timer.Stop()
+	return b
+}
+
+func (b *batchWriter) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.interval <= 0 {
+		return b.w.Write(p)
+	}
+	if len(b.buf) == 0 {
+		b.timer.Reset(b.interval)
+	}
+	b.buf = append(b.buf, p...)
+	if len(b.buf) >= outputBatchMaxBytes {
+		// writing through holds the container back while the terminal catches
+		// up, rather than gathering ever more
+		if err := b.flushLocked(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush passes on anything gathered so far.
+func (b *batchWriter) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.flushLocked()
+}
+
+func (b *batchWriter) flushLocked() error {
+	b.timer.Stop()
+	if len(b.buf) == 0 {
+		return b.err
+	}
+	_, err := b.w.Write(b.buf)
+	b.buf = b.buf[:0]
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+	return err
+}
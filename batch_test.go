@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"fyne.io/fyne/v2/test"
+	"github.com/fyne-io/terminal"
+)
+
+// benchOutput is 128KB of the sort of output the demo workload gives, as
+// lines of a package list.
+var benchOutput = func() []byte {
+	var b strings.Builder
+	for i := 0; b.Len() < 128<<10; i++ {
+		fmt.Fprintf(&b, "Package: lib-example-%d\r\nVersion: 1.%d-2\r\nDescription: an example package, number %d\r\n", i, i, i)
+	}
+	return []byte(b.String())
+}()
+
+// terminalSink is a terminal widget reading what is written to w, as in
+// reallyRun. Closing w ends it, and wait returns once it has read the lot.
+func terminalSink(b *testing.B) (w io.WriteCloser, wait func()) {
+	b.Helper()
+	t := terminal.New()
+	t.Resize(t.MinSize().AddWidthHeight(800, 400))
+	r, w := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = t.RunWithConnection(nopWriteCloser{io.Discard}, r)
+	}()
+	return w, func() { <-done }
+}
+
+// writeLines writes out to w a line at a time, as a container usually does.
+func writeLines(b *testing.B, w io.Writer, out []byte) {
+	for len(out) > 0 {
+		n := bytes.IndexByte(out, '\n') + 1
+		if n == 0 {
+			n = len(out)
+		}
+		if _, err := w.Write(out[:n]); err != nil {
+			b.Fatal(err)
+		}
+		out = out[n:]
+	}
+}
+
+// BenchmarkBatchWriter is how fast a terminal takes output written a line at a
+// time, passed straight on and batched as by default.
+func BenchmarkBatchWriter(b *testing.B) {
+	test.NewTempApp(b)
+	for _, interval := range []int{0, defaultOutputBatch} {
+		b.Run(fmt.Sprintf("%dms", interval), func(b *testing.B) {
+			b.SetBytes(int64(len(benchOutput)))
+			for b.Loop() {
+				sink, wait := terminalSink(b)
+				w := newBatchWriter(sink, time.Duration(interval)*time.Millisecond)
+				writeLines(b, w, benchOutput)
+				if err := w.Flush(); err != nil {
+					b.Fatal(err)
+				}
+				_ = sink.Close()
+				wait()
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/docker/docker/api/types/mount"
+)
+
+// bindRow is one host path shared with the container.
+type bindRow struct {
+	host     *widget.Entry
+	target   *widget.Entry
+	readOnly *widget.Check
+	row      fyne.CanvasObject
+}
+
+// bindMountList edits the bind mounts, a row each of host path, container
+// path and whether it is read only.
+type bindMountList struct {
+	parent fyne.Window
+	rows   []*bindRow
+	box    *fyne.Container
+}
+
+func newBindMountList(parent fyne.Window) *bindMountList {
+	return &bindMountList{parent: parent, box: container.NewVBox()}
+}
+
+func (l *bindMountList) widget() fyne.CanvasObject {
+	return container.NewVBox(
+		l.box,
+		container.NewHBox(
+			widget.NewButton("Add", func() { l.add("", "", false) }),
+			widget.NewButton("Add Folder…", l.pickFolder),
+		),
+	)
+}
+
+// add appends a row, it must be called from the UI goroutine.
+func (l *bindMountList) add(host, target string, readOnly bool) {
+	r := &bindRow{
+		host:     widget.NewEntry(),
+		target:   widget.NewEntry(),
+		readOnly: widget.NewCheck("Read only", nil),
+	}
+	r.host.SetPlaceHolder("/path/on/host")
+	r.host.SetText(host)
+	r.target.SetPlaceHolder("/path/in/container")
+	r.target.SetText(target)
+	r.target.Validator = optional(func(s string) error {
+		if !path.IsAbs(s) {
+			return errors.New("must be an absolute path")
+		}
+		return nil
+	})
+	r.readOnly.SetChecked(readOnly)
+	remove := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() { l.remove(r) })
+	r.row = container.NewBorder(nil, nil, nil, container.NewHBox(r.readOnly, remove),
+		container.NewGridWithColumns(2, r.host, r.target))
+	l.rows = append(l.rows, r)
+	l.box.Add(r.row)
+}
+
+func (l *bindMountList) remove(r *bindRow) {
+	for i, row := range l.rows {
+		if row == r {
+			l.rows = append(l.rows[:i], l.rows[i+1:]...)
+			break
+		}
+	}
+	l.box.Remove(r.row)
+}
+
+// pickFolder adds a row for a host folder chosen from a dialog, mounted under
+// /mnt by default.
+func (l *bindMountList) pickFolder() {
+	dialog.ShowFolderOpen(func(dir fyne.ListableURI, err error) {
+		if err != nil {
+			dialog.ShowError(err, l.parent)
+			return
+		}
+		if dir == nil {
+			return
+		}
+		l.add(dir.Path(), "/mnt/"+filepath.Base(dir.Path()), false)
+	}, l.parent)
+}
+
+// mounts converts the rows into bind mounts, skipping any left blank.
+func (l *bindMountList) mounts() ([]mount.Mount, error) {
+	var mounts []mount.Mount
+	for _, r := range l.rows {
+		host, target := strings.TrimSpace(r.host.Text), strings.TrimSpace(r.target.Text)
+		if host == "" && target == "" {
+			continue
+		}
+		if host == "" || target == "" {
+			return nil, fmt.Errorf("bind mount %q:%q needs both a host and a container path", host, target)
+		}
+		if !path.IsAbs(target) {
+			return nil, fmt.Errorf("mount point %q for %s is not absolute", target, host)
+		}
+		// the daemon needs an absolute path, and knows nothing of ~
+		src, err := filepath.Abs(resolvePath(".", host))
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   src,
+			Target:   target,
+			ReadOnly: r.readOnly.Checked,
+		})
+	}
+	return mounts, nil
+}
+
+// missingBindSources lists the host paths of bind mounts that don't exist,
+// which the daemon would otherwise refuse only once the run had started.
+func missingBindSources(mounts []mount.Mount) []string {
+	var missing []string
+	for _, m := range mounts {
+		if m.Type != mount.TypeBind {
+			continue
+		}
+		if _, err := os.Stat(m.Source); err != nil {
+			missing = append(missing, m.Source)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types/build"
+	"github.com/docker/go-units"
+)
+
+const (
+	// buildTag is the tag given to images built before a run, each build
+	// replaces the last one.
+	buildTag = "fyne-terminal-slow-build:latest"
+	// largeBuildContext is the size past which a build context probably holds
+	// more than was intended.
+	largeBuildContext = 100 << 20
+)
+
+// ignorePattern is one line of a .dockerignore file.
+type ignorePattern struct {
+	segments []string
+	negate   bool
+}
+
+// readDockerignore loads the patterns from dir/.dockerignore, if there is one.
+func readDockerignore(dir string) ([]ignorePattern, error) {
+	f, err := os.Open(filepath.Join(dir, ".dockerignore"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseDockerignore(f)
+}
+
+// parseDockerignore parses patterns the way the docker CLI does: blank lines
+// and # comments are skipped, a leading ! re-includes what earlier patterns
+// excluded, and ** matches any number of directories.
+func parseDockerignore(r io.Reader) ([]ignorePattern, error) {
+	var patterns []ignorePattern
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var p ignorePattern
+		if p.negate = strings.HasPrefix(line, "!"); p.negate {
+			line = strings.TrimSpace(line[1:])
+		}
+		line = strings.TrimPrefix(path.Clean(filepath.ToSlash(line)), "/")
+		if line == "" || line == "." {
+			continue
+		}
+		p.segments = strings.Split(line, "/")
+		for _, seg := range p.segments {
+			if _, err := path.Match(seg, ""); err != nil {
+				return nil, fmt.Errorf("bad .dockerignore pattern %q: %w", line, err)
+			}
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, scanner.Err()
+}
+
+// matchSegments matches a slash separated path against a pattern, segment by
+// segment.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matches reports whether the pattern matches rel or any directory it is in.
+func (p ignorePattern) matches(rel string) bool {
+	name := strings.Split(rel, "/")
+	for i := len(name); i > 0; i-- {
+		if matchSegments(p.segments, name[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isIgnored applies the patterns in order, so the last one to match wins.
+func isIgnored(patterns []ignorePattern, rel string) bool {
+	// these are always sent, the daemon needs them
+	if rel == "Dockerfile" || rel == ".dockerignore" {
+		return false
+	}
+	ignored := false
+	for _, p := range patterns {
+		if p.matches(rel) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// walkContext calls fn for each file, directory and symlink under dir that
+// the patterns don't exclude, with its slash separated path relative to dir.
+func walkContext(dir string, patterns []ignorePattern, fn func(rel string, info fs.FileInfo) error) error {
+	// an excluded directory can only be skipped outright if nothing inside it
+	// could be re-included
+	canSkip := true
+	for _, p := range patterns {
+		canSkip = canSkip && !p.negate
+	}
+	return filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if isIgnored(patterns, rel) {
+			if d.IsDir() && canSkip {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() && info.Mode()&fs.ModeSymlink == 0 {
+			// sockets, devices etc can't go in the context
+			return nil
+		}
+		return fn(rel, info)
+	})
+}
+
+// contextSize adds up the size of the files that would be sent.
+func contextSize(dir string, patterns []ignorePattern) (size int64, files int, err error) {
+	err = walkContext(dir, patterns, func(rel string, info fs.FileInfo) error {
+		if info.Mode().IsRegular() {
+			size += info.Size()
+			files++
+		}
+		return nil
+	})
+	return size, files, err
+}
+
+// writeContextTar writes the filtered contents of dir to w as a tar stream.
+func writeContextTar(w io.Writer, dir string, patterns []ignorePattern) error {
+	tw := tar.NewWriter(w)
+	err := walkContext(dir, patterns, func(rel string, info fs.FileInfo) error {
+		file := filepath.Join(dir, filepath.FromSlash(rel))
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			var err error
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		// like the docker CLI, everything in the context is owned by root
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// buildImage builds the image in dir, from its Dockerfile or the one given,
+// sending only what its .dockerignore allows, and returns the tag to run it by.
+func buildImage(ctx context.Context, dc dockerClient, dir, dockerfile string, out io.Writer) (string, error) {
+	patterns, err := readDockerignore(dir)
+	if err != nil {
+		return "", err
+	}
+	if dockerfile != "" {
+		dockerfile = filepath.ToSlash(filepath.Clean(dockerfile))
+		// the daemon needs it whatever .dockerignore says, like the Dockerfile
+		patterns = append(patterns, ignorePattern{segments: strings.Split(dockerfile, "/"), negate: true})
+	}
+	size, files, err := contextSize(dir, patterns)
+	if err != nil {
+		return "", fmt.Errorf("unable to read build context: %w", err)
+	}
+	_, _ = fmt.Fprintf(out, "Sending build context: %d files, %s\r\n", files, units.HumanSize(float64(size)))
+	if size > largeBuildContext {
+		_, _ = fmt.Fprintf(out, "Warning: the build context is over %s, add a .dockerignore to leave out what the build doesn't need\r\n",
+			units.HumanSize(largeBuildContext))
+	}
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		pw.CloseWithError(writeContextTar(pw, dir, patterns))
+	}()
+	resp, err := dc.ImageBuild(ctx, pr, build.ImageBuildOptions{
+		Tags:        []string{buildTag},
+		Dockerfile:  dockerfile,
+		Remove:      true,
+		ForceRemove: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to build image: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := showProgress(resp.Body, out); err != nil {
+		return "", fmt.Errorf("image build failed: %w", err)
+	}
+	return buildTag, nil
+}
+
+// crlfWriter turns bare line feeds into the CR LF the terminal needs, for
+// output that doesn't come through a TTY.
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (c crlfWriter) Write(p []byte) (int, error) {
+	if _, err := c.w.Write([]byte(strings.ReplaceAll(string(p), "\n", "\r\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
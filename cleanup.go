@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	dockerContainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+)
+
+const (
+	// appLabel is set on every container the app creates, so that any left
+	// behind, e.g. by a crash or a kept container, can be found again.
+	appLabel = "com.github.mgabeler-lee-6rs.fyne-terminal-slow"
+	// maxCleanupListed is how many of the containers to remove are named when
+	// asking to.
+	maxCleanupListed = 10
+)
+
+// withAppLabel adds appLabel to the user's labels.
+func withAppLabel(labels map[string]string) map[string]string {
+	labels = maps.Clone(labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[appLabel] = "true"
+	return labels
+}
+
+// leftoverContainers lists the containers the app created, running or not,
+// other than those of the runs still going on.
+func leftoverContainers(daemon dockerDaemon, inUse []string) ([]dockerContainer.Summary, error) {
+	dc, err := newRawDockerClient(daemon)
+	if err != nil {
+		return nil, err
+	}
+	defer dc.Close()
+	found, err := dc.ContainerList(context.Background(), dockerContainer.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", appLabel)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list containers: %w", err)
+	}
+	return slices.DeleteFunc(found, func(c dockerContainer.Summary) bool {
+		return slices.Contains(inUse, c.ID)
+	}), nil
+}
+
+// containerSummary describes a container for the user to recognise, by its
+// name and image and what state it is in.
+func containerSummary(c dockerContainer.Summary) string {
+	name := shortID(c.ID)
+	if len(c.Names) > 0 {
+		name = strings.TrimPrefix(c.Names[0], "/")
+	}
+	return fmt.Sprintf("%s (%s, %s)", name, c.Image, c.Status)
+}
+
+// cleanupContainers offers to remove every container the app has left behind
+// on the form's daemon, stopping any still running.
+func (s *AppState) cleanupContainers() {
+	daemon := s.options.daemon()
+	var inUse []string
+	for _, sess := range s.sessions {
+		if c := sess.currentContainer(); c != nil {
+			inUse = append(inUse, c.id)
+		}
+	}
+	go func() {
+		leftover, err := leftoverContainers(daemon, inUse)
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(err, s.mainWindow)
+				return
+			}
+			if len(leftover) == 0 {
+				dialog.ShowInformation("Remove Leftover Containers", "No containers have been left behind.", s.mainWindow)
+				return
+			}
+			var names []string
+			for _, c := range leftover[:min(len(leftover), maxCleanupListed)] {
+				names = append(names, containerSummary(c))
+			}
+			if len(leftover) > maxCleanupListed {
+				names = append(names, fmt.Sprintf("and %d more", len(leftover)-maxCleanupListed))
+			}
+			msg := fmt.Sprintf("Remove the %d containers left behind by earlier runs?\n\n%s",
+				len(leftover), strings.Join(names, "\n"))
+			dialog.ShowConfirm("Remove Leftover Containers", msg, func(ok bool) {
+				if ok {
+					go s.removeContainers(daemon, leftover)
+				}
+			}, s.mainWindow)
+		})
+	}()
+}
+
+// removeContainers force removes the containers, reporting any that couldn't
+// be.
+func (s *AppState) removeContainers(daemon dockerDaemon, containers []dockerContainer.Summary) {
+	err := func() error {
+		dc, err := newRawDockerClient(daemon)
+		if err != nil {
+			return err
+		}
+		defer dc.Close()
+		var errs []error
+		for _, c := range containers {
+			err := dc.ContainerRemove(context.Background(), c.ID, dockerContainer.RemoveOptions{Force: true})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", containerSummary(c), err))
+			}
+		}
+		return errors.Join(errs...)
+	}()
+	fyne.Do(func() {
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("unable to remove every container: %w", err), s.mainWindow)
+			return
+		}
+		dialog.ShowInformation("Remove Leftover Containers",
+			fmt.Sprintf("Removed %d containers.", len(containers)), s.mainWindow)
+	})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// parseCommand reads the subcommand, if any, left after the app's own flags.
+// Only run is known, which gives the options to start a run with at once, as
+// with -exit-with-run but without the saved options. With no subcommand opts
+// is nil, for the usual idle window.
+func parseCommand(args []string, stderr io.Writer) (opts *runOptions, err error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	if args[0] != "run" {
+		return nil, fmt.Errorf("unknown command %q, only run is supported", args[0])
+	}
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "usage: %s [flags] run --image IMAGE [--cmd COMMAND]\n\n"+
+			"Runs IMAGE in the window, prints its output once it exits and quits\n"+
+			"with its exit code.\n\n", flag.CommandLine.Name())
+		fs.PrintDefaults()
+	}
+	image := fs.String("image", "", "the `image` to run")
+	cmd := fs.String("cmd", "", "the `command` to run in it, split as sh would, the image's own if empty")
+	if err := fs.Parse(args[1:]); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments to run: %q", fs.Args())
+	}
+	if *image == "" {
+		return nil, errors.New("run needs an --image")
+	}
+	opts = &runOptions{Image: *image}
+	if opts.Cmd, err = shellSplit(*cmd); err != nil {
+		return nil, fmt.Errorf("invalid --cmd: %w", err)
+	}
+	// there is no form to fix them in
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
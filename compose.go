@@ -0,0 +1,371 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/go-connections/nat"
+	"gopkg.in/yaml.v3"
+)
+
+// composeKeys are the service settings that can be translated into a run,
+// anything else in the service is reported as unsupported and ignored.
+var composeKeys = map[string]bool{
+	"image":          true,
+	"build":          true,
+	"command":        true,
+	"entrypoint":     true,
+	"environment":    true,
+	"volumes":        true,
+	"ports":          true,
+	"hostname":       true,
+	"domainname":     true,
+	"container_name": true,
+	"stop_signal":    true,
+	"privileged":     true,
+	"read_only":      true,
+	"tmpfs":          true,
+	"working_dir":    true,
+	"user":           true,
+	"network_mode":   true,
+	"extra_hosts":    true,
+	// every run is interactive anyway
+	"tty":        true,
+	"stdin_open": true,
+}
+
+// composeFile is a parsed compose.yaml, from which a single service can be
+// run. No attempt is made to orchestrate the project as a whole.
+type composeFile struct {
+	dir      string
+	services map[string]yaml.Node
+}
+
+func loadCompose(path string) (*composeFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Services map[string]yaml.Node `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+	if len(doc.Services) == 0 {
+		return nil, fmt.Errorf("%s defines no services", path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	return &composeFile{dir: filepath.Dir(abs), services: doc.Services}, nil
+}
+
+func (c *composeFile) names() []string {
+	names := make([]string, 0, len(c.services))
+	for name := range c.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// service decodes the named service, also returning the settings it uses
+// that can't be translated.
+func (c *composeFile) service(name string) (composeService, []string, error) {
+	var svc composeService
+	node, ok := c.services[name]
+	if !ok {
+		return svc, nil, fmt.Errorf("no service %q", name)
+	}
+	var keys map[string]yaml.Node
+	if err := node.Decode(&keys); err != nil {
+		return svc, nil, fmt.Errorf("service %s: %w", name, err)
+	}
+	var unsupported []string
+	for key := range keys {
+		if !composeKeys[key] {
+			unsupported = append(unsupported, key)
+		}
+	}
+	sort.Strings(unsupported)
+	if err := node.Decode(&svc); err != nil {
+		return svc, unsupported, fmt.Errorf("service %s: %w", name, err)
+	}
+	return svc, unsupported, nil
+}
+
+// composeService is the part of a compose service definition that is used.
+type composeService struct {
+	Image       string       `yaml:"image"`
+	Build       composeBuild `yaml:"build"`
+	Command     commandLine  `yaml:"command"`
+	Entrypoint  commandLine  `yaml:"entrypoint"`
+	Environment composeEnv   `yaml:"environment"`
+	Volumes     scalarList   `yaml:"volumes"`
+	Ports       scalarList   `yaml:"ports"`
+	Hostname    string       `yaml:"hostname"`
+	Name        string       `yaml:"container_name"`
+	Domainname  string       `yaml:"domainname"`
+	StopSignal  string       `yaml:"stop_signal"`
+	Privileged  bool         `yaml:"privileged"`
+	ReadOnly    bool         `yaml:"read_only"`
+	Tmpfs       scalarList   `yaml:"tmpfs"`
+	WorkingDir  string       `yaml:"working_dir"`
+	User        string       `yaml:"user"`
+	NetworkMode string       `yaml:"network_mode"`
+	ExtraHosts  scalarList   `yaml:"extra_hosts"`
+}
+
+// apply fills in opts from the service, leaving alone whatever the form
+// already set. Relative paths are taken from dir, as compose does.
+func (svc composeService) apply(opts *runOptions, dir string) error {
+	opts.Image = svc.Image
+	if svc.Build.Context != "" && opts.BuildContext == "" {
+		opts.BuildContext = resolvePath(dir, svc.Build.Context)
+		opts.Dockerfile = svc.Build.Dockerfile
+	}
+	if opts.BuildContext == "" && opts.Image == "" {
+		return errors.New("service has neither an image nor a build")
+	}
+	if opts.Cmd == nil {
+		opts.Cmd = svc.Command
+	}
+	if opts.Entrypoint == nil {
+		opts.Entrypoint = svc.Entrypoint
+	}
+	opts.Env = mergeEnv(svc.Environment, opts.Env)
+	for _, v := range svc.Volumes {
+		m, err := parseComposeVolume(v, dir)
+		if err != nil {
+			return err
+		}
+		opts.Volumes = append(opts.Volumes, m)
+	}
+	if len(svc.Ports) > 0 {
+		exposed, bindings, err := nat.ParsePortSpecs(svc.Ports)
+		if err != nil {
+			return fmt.Errorf("invalid ports: %w", err)
+		}
+		// on top of any published from the form
+		if opts.ExposedPorts == nil {
+			opts.ExposedPorts, opts.PortBindings = nat.PortSet{}, nat.PortMap{}
+		}
+		for p := range exposed {
+			opts.ExposedPorts[p] = struct{}{}
+		}
+		for p, b := range bindings {
+			opts.PortBindings[p] = append(opts.PortBindings[p], b...)
+		}
+	}
+	if opts.Hostname == "" {
+		opts.Hostname = svc.Hostname
+	}
+	if opts.Domainname == "" {
+		opts.Domainname = svc.Domainname
+	}
+	if opts.Name == "" {
+		opts.Name = svc.Name
+	}
+	if opts.StopSignal == "" {
+		opts.StopSignal = svc.StopSignal
+	}
+	if opts.WorkingDir == "" {
+		opts.WorkingDir = svc.WorkingDir
+	}
+	if opts.User == "" {
+		opts.User = svc.User
+	}
+	if opts.Network == "" {
+		opts.Network = svc.NetworkMode
+	}
+	opts.ExtraHosts = append(opts.ExtraHosts, svc.ExtraHosts...)
+	opts.Privileged = opts.Privileged || svc.Privileged
+	opts.ReadonlyRootfs = opts.ReadonlyRootfs || svc.ReadOnly
+	if len(svc.Tmpfs) > 0 {
+		tmpfs, err := parseTmpfs(strings.Join(svc.Tmpfs, "\n"))
+		if err != nil {
+			return fmt.Errorf("invalid tmpfs: %w", err)
+		}
+		// on top of any from the form
+		if opts.Tmpfs == nil {
+			opts.Tmpfs = map[string]string{}
+		}
+		maps.Copy(opts.Tmpfs, tmpfs)
+	}
+	return nil
+}
+
+// parseComposeVolume parses the short volume syntax, [SOURCE:]TARGET[:MODE],
+// where a SOURCE that looks like a path is a bind mount and anything else
+// names a volume.
+func parseComposeVolume(spec, dir string) (mount.Mount, error) {
+	parts := strings.Split(spec, ":")
+	m := mount.Mount{Type: mount.TypeVolume}
+	switch len(parts) {
+	case 1:
+		// an anonymous volume
+		m.Target = parts[0]
+	case 2, 3:
+		m.Source, m.Target = parts[0], parts[1]
+		if len(parts) == 3 {
+			switch parts[2] {
+			case "ro":
+				m.ReadOnly = true
+			case "rw":
+			default:
+				return m, fmt.Errorf("volume %q: unsupported mode %q", spec, parts[2])
+			}
+		}
+	default:
+		return m, fmt.Errorf("volume %q is not of the form [SOURCE:]TARGET[:MODE]", spec)
+	}
+	if !filepath.IsAbs(m.Target) {
+		return m, fmt.Errorf("volume %q: mount point %q is not absolute", spec, m.Target)
+	}
+	if strings.HasPrefix(m.Source, ".") || strings.HasPrefix(m.Source, "~") || strings.Contains(m.Source, "/") {
+		m.Type, m.Source = mount.TypeBind, resolvePath(dir, m.Source)
+	} else if m.Source != "" {
+		if err := validateVolumeName(m.Source); err != nil {
+			return m, err
+		}
+	}
+	return m, nil
+}
+
+// resolvePath expands ~ and makes p absolute relative to dir.
+func resolvePath(dir, p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = home + p[1:]
+		}
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, p)
+	}
+	return filepath.Clean(p)
+}
+
+// composeBuild is either just the context directory, or a mapping of which
+// only the context and Dockerfile can be used.
+type composeBuild struct {
+	Context    string
+	Dockerfile string
+}
+
+func (b *composeBuild) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		b.Context = n.Value
+		return nil
+	}
+	var full struct {
+		Context    string `yaml:"context"`
+		Dockerfile string `yaml:"dockerfile"`
+	}
+	if err := n.Decode(&full); err != nil {
+		return err
+	}
+	b.Context, b.Dockerfile = full.Context, full.Dockerfile
+	if b.Context == "" {
+		b.Context = "."
+	}
+	return nil
+}
+
+// commandLine is a command given either as a list or a single string, which
+// is split like a shell would.
+type commandLine []string
+
+func (c *commandLine) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		args, err := shellSplit(n.Value)
+		*c = args
+		return err
+	}
+	return n.Decode((*[]string)(c))
+}
+
+// scalarList is a list of strings, written in compose files as strings or
+// numbers, e.g. ports.
+type scalarList []string
+
+func (l *scalarList) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind != yaml.SequenceNode {
+		return fmt.Errorf("line %d: expected a list", n.Line)
+	}
+	for _, item := range n.Content {
+		if item.Kind != yaml.ScalarNode {
+			return fmt.Errorf("line %d: only the short syntax is supported", item.Line)
+		}
+		*l = append(*l, item.Value)
+	}
+	return nil
+}
+
+// composeEnv is the environment as either a list of NAME=VALUE or a mapping.
+// A name without a value takes it from the host, as compose does.
+type composeEnv []string
+
+func (e *composeEnv) UnmarshalYAML(n *yaml.Node) error {
+	add := func(name string, value *string) {
+		if value == nil {
+			v, ok := os.LookupEnv(name)
+			if !ok {
+				return
+			}
+			value = &v
+		}
+		*e = append(*e, name+"="+*value)
+	}
+	switch n.Kind {
+	case yaml.SequenceNode:
+		var list []string
+		if err := n.Decode(&list); err != nil {
+			return err
+		}
+		for _, kv := range list {
+			if name, value, ok := strings.Cut(kv, "="); ok {
+				add(name, &value)
+			} else {
+				add(name, nil)
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			name, value := n.Content[i].Value, n.Content[i+1]
+			if value.Tag == "!!null" {
+				add(name, nil)
+			} else {
+				add(name, &value.Value)
+			}
+		}
+	default:
+		return fmt.Errorf("line %d: environment must be a list or a mapping", n.Line)
+	}
+	return nil
+}
+
+// warnUnsupported tells the user which of a service's settings will be
+// ignored, or why it can't be run at all.
+func warnUnsupported(parent fyne.Window, c *composeFile, name string) {
+	_, unsupported, err := c.service(name)
+	if err != nil {
+		dialog.ShowError(err, parent)
+		return
+	}
+	if len(unsupported) > 0 {
+		dialog.ShowInformation("Unsupported Settings",
+			fmt.Sprintf("Service %s uses settings that can't be run here and will be ignored:\n\n%s",
+				name, strings.Join(unsupported, ", ")),
+			parent)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	cerrdefs "github.com/containerd/errdefs"
+	dockerContainer "github.com/docker/docker/api/types/container"
+)
+
+// containerNamePattern is what the daemon accepts as a container name.
+var containerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+func validateContainerName(name string) error {
+	if !containerNamePattern.MatchString(name) {
+		return fmt.Errorf("%q is not a valid container name, use [a-zA-Z0-9_.-] not starting with punctuation", name)
+	}
+	return nil
+}
+
+// maxNameSuffix is how far freeContainerName counts looking for a name not
+// yet in use.
+const maxNameSuffix = 100
+
+// nameConflict is what to do about a container name already being in use.
+type nameConflict int
+
+const (
+	nameConflictCancel nameConflict = iota
+	// nameConflictReplace removes the container using the name
+	nameConflictReplace
+	// nameConflictSuffix adds a number to the name, see freeContainerName
+	nameConflictSuffix
+)
+
+// createNamed creates the container with create, or if the name is taken
+// asks what to do about it with nameInUse.
+func createNamed(
+	ctx context.Context,
+	dc dockerClient,
+	name string,
+	create func(name string) (dockerContainer.CreateResponse, error),
+	nameInUse func(name string) nameConflict,
+) (dockerContainer.CreateResponse, error) {
+	created, err := create(name)
+	if name == "" || !cerrdefs.IsConflict(err) {
+		return created, err
+	}
+	switch nameInUse(name) {
+	case nameConflictReplace:
+		if err := dc.ContainerRemove(ctx, name, dockerContainer.RemoveOptions{Force: true}); err != nil {
+			return created, fmt.Errorf("unable to remove the container named %s: %w", name, err)
+		}
+		return create(name)
+	case nameConflictSuffix:
+		free, err := freeContainerName(ctx, dc, name)
+		if err != nil {
+			return created, err
+		}
+		return create(free)
+	default:
+		return created, err
+	}
+}
+
+// freeContainerName finds the first of name-2, name-3 and so on that no
+// container has.
+func freeContainerName(ctx context.Context, dc dockerClient, name string) (string, error) {
+	for i := 2; i <= maxNameSuffix; i++ {
+		candidate := fmt.Sprintf("%s-%d", name, i)
+		_, err := dc.ContainerInspect(ctx, candidate)
+		if cerrdefs.IsNotFound(err) {
+			return candidate, nil
+		} else if err != nil {
+			return "", fmt.Errorf("unable to check for a container named %s: %w", candidate, err)
+		}
+	}
+	return "", fmt.Errorf("containers named %s-2 to %s-%d all exist", name, name, maxNameSuffix)
+}
+
+// askNameConflict asks the user, from a background goroutine, what to do
+// about a container already having the name, blocking until they answer.
+func (s *session) askNameConflict(name string) nameConflict {
+	answer := make(chan nameConflict, 1)
+	fyne.Do(func() {
+		var d *dialog.CustomDialog
+		choose := func(c nameConflict) func() {
+			return func() {
+				answer <- c
+				d.Hide()
+			}
+		}
+		msg := widget.NewLabel(fmt.Sprintf("There is already a container named %s.\n\n"+
+			"Removing it stops it first if it is running.", name))
+		d = dialog.NewCustomWithoutButtons("Container Name in Use", msg, s.mainWindow)
+		d.SetButtons([]fyne.CanvasObject{
+			widget.NewButton("Cancel", choose(nameConflictCancel)),
+			widget.NewButton("Use "+name+"-N", choose(nameConflictSuffix)),
+			&widget.Button{Text: "Remove It", Importance: widget.DangerImportance, OnTapped: choose(nameConflictReplace)},
+		})
+		d.Show()
+	})
+	return <-answer
+}
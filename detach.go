@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"io"
+
+	"fyne.io/fyne/v2"
+	"github.com/moby/term"
+)
+
+const (
+	// prefDetachKeys is the key sequence that detaches from the container,
+	// leaving it running, in the docker CLI's --detach-keys format.
+	prefDetachKeys    = keymapPrefPrefix + "detachKeys"
+	defaultDetachKeys = "ctrl-p,ctrl-q"
+)
+
+// errDetached is how the input ends when the user types the detach keys.
+var errDetached = errors.New("detached")
+
+// parseDetachKeys parses a comma separated key sequence, e.g. ctrl-p,ctrl-q,
+// as docker's --detach-keys does.
+func parseDetachKeys(s string) ([]byte, error) {
+	keys, err := term.ToBytes(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no keys given")
+	}
+	return keys, nil
+}
+
+// detachKeys is the configured detach sequence, as written and as the bytes
+// typed, the default if it isn't set or is somehow invalid.
+func detachKeys(prefs fyne.Preferences) (string, []byte) {
+	spec := prefs.StringWithFallback(prefDetachKeys, defaultDetachKeys)
+	keys, err := parseDetachKeys(spec)
+	if err != nil {
+		fyne.LogError("ignoring invalid detach keys", err)
+		spec = defaultDetachKeys
+		keys, _ = parseDetachKeys(spec)
+	}
+	return spec, keys
+}
+
+// detachingReader passes on r until the detach sequence is read from it, which
+// it holds back, ending with errDetached instead.
+type detachingReader struct {
+	r io.Reader
+}
+
+func newDetachingReader(r io.Reader, keys []byte) io.Reader {
+	return detachingReader{term.NewEscapeProxy(r, keys)}
+}
+
+func (d detachingReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if errors.As(err, &term.EscapeError{}) {
+		err = errDetached
+	}
+	return n, err
+}
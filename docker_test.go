@@ -0,0 +1,81 @@
+//go:build docker
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	dockerContainer "github.com/docker/docker/api/types/container"
+)
+
+// TestRunContainerDocker runs a real container through runContainer, against
+// the daemon the environment says to use. Run it with go test -tags docker.
+func TestRunContainerDocker(t *testing.T) {
+	dc, err := newRawDockerClient(dockerDaemon{})
+	if err != nil {
+		t.Skipf("no docker client: %v", err)
+	}
+	defer dc.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	pingCtx, pingCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer pingCancel()
+	if _, err := dc.Ping(pingCtx); err != nil {
+		t.Skipf("docker daemon unreachable: %v", err)
+	}
+
+	stdinR, stdinW := io.Pipe()
+	defer stdinW.Close()
+	stdoutR, stdoutW := io.Pipe()
+	var out bytes.Buffer
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		_, _ = io.Copy(&out, stdoutR)
+	}()
+
+	var mu sync.Mutex
+	exitCode := -1
+	hooks := runHooks{
+		created:  func(id, image string) {},
+		status:   func(text string, failed bool) {},
+		output:   func() {},
+		finished: func(text string, failed bool) {},
+		exited: func(code int) {
+			mu.Lock()
+			defer mu.Unlock()
+			exitCode = code
+		},
+		nameInUse: func(name string) nameConflict { return nameConflictCancel },
+	}
+	cfg := &dockerContainer.Config{
+		Image:     "busybox",
+		Cmd:       []string{"echo", "hello from busybox"},
+		OpenStdin: true,
+		StdinOnce: true,
+	}
+	hostCfg := &dockerContainer.HostConfig{AutoRemove: true}
+	getTermSize := func() (uint, uint, error) { return fallbackRows, fallbackCols, nil }
+
+	err = runContainer(ctx, dc, cfg, hostCfg, nil, runOptions{}, getTermSize, nil, hooks, stdinR, stdoutW, nil)
+	_ = stdinR.CloseWithError(errRunEnded)
+	_ = stdoutW.Close()
+	<-copied
+	if err != nil {
+		t.Fatalf("run failed: %v\n%s", err, out.String())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if exitCode != 0 {
+		t.Errorf("exit code %d, want 0", exitCode)
+	}
+	if !strings.Contains(out.String(), "hello from busybox\r\n") {
+		t.Errorf("output %q doesn't have the container's", out.String())
+	}
+}
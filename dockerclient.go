@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"io"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/build"
+	dockerContainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// dockerClient is the part of the docker API a run uses, which a
+// *client.Client provides. It is kept to just those methods so that a fake
+// only has that much to implement.
+type dockerClient interface {
+	ContainerCreate(ctx context.Context, config *dockerContainer.Config, hostConfig *dockerContainer.HostConfig,
+		networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string,
+	) (dockerContainer.CreateResponse, error)
+	ContainerInspect(ctx context.Context, container string) (dockerContainer.InspectResponse, error)
+	ContainerAttach(ctx context.Context, container string, options dockerContainer.AttachOptions) (types.HijackedResponse, error)
+	ContainerLogs(ctx context.Context, container string, options dockerContainer.LogsOptions) (io.ReadCloser, error)
+	ContainerStart(ctx context.Context, container string, options dockerContainer.StartOptions) error
+	ContainerWait(ctx context.Context, container string, condition dockerContainer.WaitCondition,
+	) (<-chan dockerContainer.WaitResponse, <-chan error)
+	ContainerResize(ctx context.Context, container string, options dockerContainer.ResizeOptions) error
+	ContainerKill(ctx context.Context, container, signal string) error
+	ContainerUpdate(ctx context.Context, container string, updateConfig dockerContainer.UpdateConfig,
+	) (dockerContainer.UpdateResponse, error)
+	ContainerStop(ctx context.Context, container string, options dockerContainer.StopOptions) error
+	ContainerRemove(ctx context.Context, container string, options dockerContainer.RemoveOptions) error
+
+	ContainerExecCreate(ctx context.Context, container string, options dockerContainer.ExecOptions,
+	) (dockerContainer.ExecCreateResponse, error)
+	ContainerExecAttach(ctx context.Context, execID string, options dockerContainer.ExecAttachOptions,
+	) (types.HijackedResponse, error)
+	ContainerExecResize(ctx context.Context, execID string, options dockerContainer.ResizeOptions) error
+	ContainerExecInspect(ctx context.Context, execID string) (dockerContainer.ExecInspect, error)
+
+	ImageBuild(ctx context.Context, context io.Reader, options build.ImageBuildOptions) (build.ImageBuildResponse, error)
+	ImagePull(ctx context.Context, ref string, options image.PullOptions) (io.ReadCloser, error)
+
+	NetworkList(ctx context.Context, options network.ListOptions) ([]network.Summary, error)
+
+	VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error)
+	VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error)
+
+	DaemonHost() string
+	Close() error
+}
+
+var _ dockerClient = (*client.Client)(nil)
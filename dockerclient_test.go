@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	dockerContainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const fakeID = "0123456789abcdef0123"
+
+// fakeDocker is a dockerClient running one pretend container, which once
+// started writes output to its TTY and exits with exitCode, or if forever is
+// set carries on until it is stopped. Calls it doesn't
+// fake panic, on the nil dockerClient it embeds. The func fields, where set,
+// replace what it does for that call.
+type fakeDocker struct {
+	dockerClient
+
+	output   string
+	exitCode int64
+	forever  bool
+
+	create func(ctx context.Context, name string) (dockerContainer.CreateResponse, error)
+	attach func(ctx context.Context, id string) (types.HijackedResponse, error)
+	// wait is given which call to ContainerWait this is, from 0
+	wait func(ctx context.Context, id string, call int) (<-chan dockerContainer.WaitResponse, <-chan error)
+	pull func(ctx context.Context, ref string) (io.ReadCloser, error)
+
+	mu    sync.Mutex
+	calls []string
+	waits int
+	// attachOpts is what the last attach asked for
+	attachOpts dockerContainer.AttachOptions
+	// conn is the container's end of the attach
+	conn     net.Conn
+	exited   chan struct{}
+	exitOnce sync.Once
+}
+
+func newFakeDocker(output string, exitCode int64) *fakeDocker {
+	return &fakeDocker{output: output, exitCode: exitCode, exited: make(chan struct{})}
+}
+
+func (f *fakeDocker) record(format string, args ...any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, fmt.Sprintf(format, args...))
+}
+
+// called reports whether call was made, as record gave it.
+func (f *fakeDocker) called(call string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Contains(f.calls, call)
+}
+
+// count is how many calls to method there were.
+func (f *fakeDocker) count(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, c := range f.calls {
+		if c == method || strings.HasPrefix(c, method+" ") {
+			n++
+		}
+	}
+	return n
+}
+
+// exit ends the container with code, if it hasn't already.
+func (f *fakeDocker) exit(code int64) {
+	f.exitOnce.Do(func() {
+		f.mu.Lock()
+		f.exitCode = code
+		conn := f.conn
+		f.mu.Unlock()
+		if conn != nil {
+			_ = conn.Close()
+		}
+		close(f.exited)
+	})
+}
+
+func (f *fakeDocker) running() bool {
+	select {
+	case <-f.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+func (f *fakeDocker) ContainerCreate(ctx context.Context, _ *dockerContainer.Config, _ *dockerContainer.HostConfig,
+	_ *network.NetworkingConfig, _ *ocispec.Platform, name string,
+) (dockerContainer.CreateResponse, error) {
+	f.record("ContainerCreate")
+	if f.create != nil {
+		return f.create(ctx, name)
+	}
+	return dockerContainer.CreateResponse{ID: fakeID}, nil
+}
+
+func (f *fakeDocker) ContainerInspect(_ context.Context, id string) (dockerContainer.InspectResponse, error) {
+	f.record("ContainerInspect %s", id)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return dockerContainer.InspectResponse{
+		ContainerJSONBase: &dockerContainer.ContainerJSONBase{
+			ID:    id,
+			State: &dockerContainer.State{Running: f.running(), ExitCode: int(f.exitCode)},
+		},
+		Config: &dockerContainer.Config{},
+	}, nil
+}
+
+func (f *fakeDocker) ContainerAttach(ctx context.Context, id string, options dockerContainer.AttachOptions,
+) (types.HijackedResponse, error) {
+	f.record("ContainerAttach %s", id)
+	f.mu.Lock()
+	f.attachOpts = options
+	f.mu.Unlock()
+	if f.attach != nil {
+		return f.attach(ctx, id)
+	}
+	ours, theirs := net.Pipe()
+	f.mu.Lock()
+	f.conn = theirs
+	f.mu.Unlock()
+	// the container takes whatever it is sent
+	go func() { _, _ = io.Copy(io.Discard, theirs) }()
+	return types.NewHijackedResponse(ours, ""), nil
+}
+
+func (f *fakeDocker) ContainerStart(_ context.Context, id string, _ dockerContainer.StartOptions) error {
+	f.record("ContainerStart %s", id)
+	go func() {
+		f.mu.Lock()
+		conn, code := f.conn, f.exitCode
+		f.mu.Unlock()
+		if conn != nil {
+			_, _ = io.WriteString(conn, f.output)
+		}
+		if !f.forever {
+			f.exit(code)
+		}
+	}()
+	return nil
+}
+
+func (f *fakeDocker) ContainerWait(ctx context.Context, id string, _ dockerContainer.WaitCondition,
+) (<-chan dockerContainer.WaitResponse, <-chan error) {
+	f.record("ContainerWait %s", id)
+	f.mu.Lock()
+	call := f.waits
+	f.waits++
+	f.mu.Unlock()
+	if f.wait != nil {
+		return f.wait(ctx, id, call)
+	}
+	return f.waitExit(ctx)
+}
+
+// waitExit is what ContainerWait does unless wait says otherwise.
+func (f *fakeDocker) waitExit(ctx context.Context) (<-chan dockerContainer.WaitResponse, <-chan error) {
+	stopped := make(chan dockerContainer.WaitResponse, 1)
+	errs := make(chan error, 1)
+	go func() {
+		select {
+		case <-f.exited:
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			stopped <- dockerContainer.WaitResponse{StatusCode: f.exitCode}
+		case <-ctx.Done():
+			errs <- ctx.Err()
+		}
+	}()
+	return stopped, errs
+}
+
+func (f *fakeDocker) ContainerResize(_ context.Context, id string, options dockerContainer.ResizeOptions) error {
+	f.record("ContainerResize %s %dx%d", id, options.Height, options.Width)
+	return nil
+}
+
+func (f *fakeDocker) ContainerStop(_ context.Context, id string, _ dockerContainer.StopOptions) error {
+	f.record("ContainerStop %s", id)
+	// as SIGTERM would
+	f.exit(143)
+	return nil
+}
+
+func (f *fakeDocker) ContainerRemove(_ context.Context, id string, _ dockerContainer.RemoveOptions) error {
+	f.record("ContainerRemove %s", id)
+	return nil
+}
+
+func (f *fakeDocker) ImagePull(ctx context.Context, ref string, _ image.PullOptions) (io.ReadCloser, error) {
+	f.record("ImagePull %s", ref)
+	if f.pull != nil {
+		return f.pull(ctx, ref)
+	}
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func (f *fakeDocker) DaemonHost() string { return "fake://" }
+
+func (f *fakeDocker) Close() error { return nil }
+
+// lockedBuffer is a bytes.Buffer that may be written from several goroutines,
+// as a run's output is.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/tlsconfig"
+)
+
+// dockerTLS is how to connect to a daemon over TLS, as the docker CLI's
+// --tls* flags. The zero value leaves it to the environment (DOCKER_CERT_PATH
+// and DOCKER_TLS_VERIFY).
+type dockerTLS struct {
+	// Verify checks the daemon's certificate, against CACert if set or the
+	// system's roots if not.
+	Verify bool
+	// CACert, Cert and Key are PEM files, Cert and Key being the client's own
+	// for a daemon that requires mutual TLS.
+	CACert string
+	Cert   string
+	Key    string
+}
+
+func (t dockerTLS) enabled() bool {
+	return t.Verify || t.CACert != "" || t.Cert != "" || t.Key != ""
+}
+
+// validate checks the files given can be read, so a missing one is reported as
+// such rather than as a failure to connect.
+func (t dockerTLS) validate() error {
+	if (t.Cert == "") != (t.Key == "") {
+		return errors.New("a client certificate and key are needed together")
+	}
+	for _, f := range []struct{ what, path string }{
+		{"CA certificate", t.CACert},
+		{"certificate", t.Cert},
+		{"key", t.Key},
+	} {
+		if f.path == "" {
+			continue
+		}
+		if err := readableFile(f.path); err != nil {
+			return fmt.Errorf("TLS %s: %w", f.what, err)
+		}
+	}
+	return nil
+}
+
+// readableFile checks path is a file that can be opened to read.
+func readableFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	return nil
+}
+
+// clientOpt configures a client for t, which must be enabled. Without Verify
+// the client's own certificate is still presented, as with DOCKER_CERT_PATH
+// but not DOCKER_TLS_VERIFY.
+func (t dockerTLS) clientOpt() (client.Opt, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	if t.Verify {
+		return client.WithTLSClientConfig(t.CACert, t.Cert, t.Key), nil
+	}
+	config, err := tlsconfig.Client(tlsconfig.Options{
+		CAFile:             t.CACert,
+		CertFile:           t.Cert,
+		KeyFile:            t.Key,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tls config: %w", err)
+	}
+	return client.WithHTTPClient(&http.Client{
+		Transport:     &http.Transport{TLSClientConfig: config},
+		CheckRedirect: client.CheckRedirect,
+	}), nil
+}
+
+// dockerDaemon is the daemon to connect to, the environment's if it is the
+// zero value.
+type dockerDaemon struct {
+	host string
+	tls  dockerTLS
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types/mount"
+)
+
+// hostLocaltime is the host's zone file, shared with the container when the
+// locale is forwarded so it keeps the host's time even without TZ set.
+const hostLocaltime = "/etc/localtime"
+
+// hostLocaleEnv returns the host's time zone and locale settings, TZ, LANG,
+// LANGUAGE and LC_*, as container environment entries.
+func hostLocaleEnv() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if name == "TZ" || name == "LANG" || name == "LANGUAGE" || strings.HasPrefix(name, "LC_") {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// localtimeMount shares the host's zone file with the container, read only,
+// if the host has one.
+func localtimeMount() (mount.Mount, bool) {
+	// bind the zone file itself, not the symlink to it
+	src, err := filepath.EvalSymlinks(hostLocaltime)
+	if err != nil {
+		return mount.Mount{}, false
+	}
+	return mount.Mount{
+		Type:     mount.TypeBind,
+		Source:   src,
+		Target:   hostLocaltime,
+		ReadOnly: true,
+	}, true
+}
+
+// mergeEnv combines lists of NAME=VALUE entries, where a later list takes
+// precedence over an earlier one for the same name. Each name appears once,
+// in the position it was first given.
+func mergeEnv(lists ...[]string) []string {
+	var merged []string
+	index := map[string]int{}
+	for _, list := range lists {
+		for _, kv := range list {
+			name, _, _ := strings.Cut(kv, "=")
+			if i, ok := index[name]; ok {
+				merged[i] = kv
+				continue
+			}
+			index[name] = len(merged)
+			merged = append(merged, kv)
+		}
+	}
+	return merged
+}
+
+// parseEnv parses lines of NAME=VALUE, a value may be empty but not the =.
+func parseEnv(text string) ([]string, error) {
+	var env []string
+	for _, line := range nonEmptyLines(text) {
+		name, _, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not of the form NAME=VALUE", line)
+		}
+		if name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("%q does not start with a variable name", line)
+		}
+		env = append(env, line)
+	}
+	return env, nil
+}
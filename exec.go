@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	dockerContainer "github.com/docker/docker/api/types/container"
+)
+
+// defaultExecCmd is what is exec'd when no command is given, for a shell to
+// look around in.
+var defaultExecCmd = []string{"/bin/sh"}
+
+// runningContainer looks up the container ref, an ID or name, which must be
+// running, returning it and its name.
+func runningContainer(ctx context.Context, dc dockerClient, ref string) (dockerContainer.InspectResponse, string, error) {
+	info, err := dc.ContainerInspect(ctx, ref)
+	if err != nil {
+		return info, "", fmt.Errorf("unable to find container %s: %w", ref, err)
+	}
+	if info.State == nil || !info.State.Running {
+		return info, "", fmt.Errorf("container %s is not running", ref)
+	}
+	return info, strings.TrimPrefix(info.Name, "/"), nil
+}
+
+// execExisting runs the command in a container that is already running, like
+// docker exec. The container is left as it is, stopping the run just
+// disconnects from the command.
+func execExisting(
+	ctx context.Context,
+	dc dockerClient,
+	opts runOptions,
+	getTermSize func() (rows, cols uint, err error),
+	resized <-chan struct{},
+	hooks runHooks,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+) error {
+	info, name, err := runningContainer(ctx, dc, opts.Attach)
+	if err != nil {
+		return err
+	}
+	cmd := opts.Cmd
+	if len(opts.Commands) > 0 {
+		cmd = []string{"/bin/sh", "-c", sequenceScript(opts.Commands, opts.StopOnFailure)}
+	}
+	if len(cmd) == 0 {
+		cmd = defaultExecCmd
+	}
+	tty := stderr == nil
+	created, err := dc.ContainerExecCreate(ctx, info.ID, dockerContainer.ExecOptions{
+		User:         opts.User,
+		Tty:          tty,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		DetachKeys:   opts.DetachKeys,
+		Env:          mergeEnv([]string{"TERM=" + containerTerm}, opts.Env),
+		WorkingDir:   opts.WorkingDir,
+		Cmd:          cmd,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to exec in %s container: %w", name, err)
+	}
+	log := lifecycleLog.With("container", shortID(info.ID), "exec", shortID(created.ID))
+	log.Info("exec created", "cmd", cmd)
+	// attaching starts it
+	attached, err := dc.ContainerExecAttach(ctx, created.ID, dockerContainer.ExecAttachOptions{Tty: tty})
+	if err != nil {
+		log.Error("exec attach failed", "err", err)
+		return fmt.Errorf("unable to start exec in %s container: %w", name, err)
+	}
+	log.Info("exec attached")
+	hooks.created(info.ID, info.Config.Image)
+	_, _ = fmt.Fprintf(stdout, "Running %s in container %s (%s)\r\n", strings.Join(cmd, " "), name, shortID(info.ID))
+	hooks.status("Running in "+name, false)
+
+	doIO := hijackedIO(attached, name, log,
+		func(ctx context.Context, r dockerContainer.ResizeOptions) error {
+			return dc.ContainerExecResize(ctx, created.ID, r)
+		},
+		func(ctx context.Context, s os.Signal) error {
+			// the API can only signal the container's main process, which is
+			// the last thing wanted here
+			log.Info("signal not forwarded to exec", "signal", s)
+			return nil
+		},
+		getTermSize, resized, hooks, stdin, stdout, stderr,
+	)
+	stopDetach := context.AfterFunc(ctx, attached.Close)
+	defer stopDetach()
+	err = doIO(ctx)
+
+	if ctx.Err() != nil || errors.Is(err, errDetached) {
+		_, _ = fmt.Fprintf(stdout, "\r\n\r\nDisconnected from the command in %s\r\n", name)
+		hooks.finished("Disconnected", false)
+		return nil
+	}
+	inspectCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if ei, iErr := dc.ContainerExecInspect(inspectCtx, created.ID); iErr == nil && !ei.Running {
+		log.Info("exec exited", "exitCode", ei.ExitCode)
+		_, _ = fmt.Fprintf(stdout, "\r\n\r\nCommand exited with code %d\r\n", ei.ExitCode)
+		hooks.finished(fmt.Sprintf("Exited (%d)", ei.ExitCode), ei.ExitCode != 0)
+		hooks.exited(ei.ExitCode)
+		if ei.ExitCode != 0 {
+			return errors.Join(err, fmt.Errorf("command returned non-zero exit code %d", ei.ExitCode))
+		}
+		return err
+	}
+	_, _ = fmt.Fprintf(stdout, "\r\n\r\nThe command in %s closed its output\r\n", name)
+	hooks.finished("Disconnected", false)
+	return err
+}
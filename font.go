@@ -0,0 +1,88 @@
+package main
+
+import (
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+)
+
+const (
+	// prefFontSize is the terminal's text size, the theme's if unset.
+	prefFontSize = "terminal.fontSize"
+	minFontSize  = 6
+	maxFontSize  = 48
+	fontSizeStep = 1
+)
+
+// fontSizeTheme is the app's theme with the text size replaced, as that is
+// what the terminal widget sizes its cells from.
+type fontSizeTheme struct {
+	size float32
+}
+
+// base is the app's own theme. Not theme.Current, which while the override is
+// rendering is this one.
+func (t *fontSizeTheme) base() fyne.Theme {
+	return fyne.CurrentApp().Settings().Theme()
+}
+
+func (t *fontSizeTheme) Color(n fyne.ThemeColorName, v fyne.ThemeVariant) color.Color {
+	return t.base().Color(n, v)
+}
+
+func (t *fontSizeTheme) Font(s fyne.TextStyle) fyne.Resource {
+	return t.base().Font(s)
+}
+
+func (t *fontSizeTheme) Icon(n fyne.ThemeIconName) fyne.Resource {
+	return t.base().Icon(n)
+}
+
+func (t *fontSizeTheme) Size(n fyne.ThemeSizeName) float32 {
+	if n == theme.SizeNameText {
+		return t.size
+	}
+	return t.base().Size(n)
+}
+
+// newFontSizeTheme starts from the saved text size.
+func newFontSizeTheme(prefs fyne.Preferences) *fontSizeTheme {
+	size := float32(prefs.FloatWithFallback(prefFontSize, float64(theme.TextSize())))
+	return &fontSizeTheme{size: min(max(size, minFontSize), maxFontSize)}
+}
+
+// newTermFont wraps a session's terminal area so its text size can be
+// changed, along with every other session's.
+func (s *AppState) newTermFont(area fyne.CanvasObject) *container.ThemeOverride {
+	return container.NewThemeOverride(area, s.fontTheme)
+}
+
+// setFontSize changes every terminal's text size, saving it for next time, and
+// resizes them to match so that the container's TTY does too. Zero goes back
+// to the theme's size.
+func (s *AppState) setFontSize(size float32) {
+	if size == 0 {
+		size = theme.TextSize()
+		s.app.Preferences().RemoveValue(prefFontSize)
+	} else {
+		size = min(max(size, minFontSize), maxFontSize)
+		s.app.Preferences().SetFloat(prefFontSize, float64(size))
+	}
+	if size == s.fontTheme.size {
+		return
+	}
+	s.fontTheme.size = size
+	for _, sess := range s.sessions {
+		sess.termFont.Refresh()
+		// the same size in pixels is now a different number of rows and
+		// columns, which the widget only works out as it is resized
+		for _, t := range []fyne.CanvasObject{sess.terminal, sess.stderrTerminal} {
+			t.Resize(t.Size())
+		}
+	}
+}
+
+func (s *AppState) growFont()   { s.setFontSize(s.fontTheme.size + fontSizeStep) }
+func (s *AppState) shrinkFont() { s.setFontSize(s.fontTheme.size - fontSizeStep) }
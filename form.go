@@ -0,0 +1,695 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	// prefRecentImages is the images most recently run, newest first.
+	prefRecentImages = "image.recent"
+	maxRecentImages  = 10
+)
+
+// optionsForm holds the widgets used to edit the runOptions.
+type optionsForm struct {
+	parent fyne.Window
+	prefs  fyne.Preferences
+	// onDaemonChanged, if set, is called when the user changes how to connect
+	// to the daemon
+	onDaemonChanged func()
+
+	image      *widget.SelectEntry
+	command    *widget.Entry
+	entrypoint *widget.Entry
+	env        *widget.Entry
+	workingDir *widget.Entry
+	user       *widget.Entry
+
+	dockerHost *widget.Entry
+	tlsVerify  *widget.Check
+	tlsCACert  *widget.Entry
+	tlsCert    *widget.Entry
+	tlsKey     *widget.Entry
+	attach     *widget.Entry
+	exec       *widget.Check
+	logTail    *widget.Entry
+	logSince   *widget.Entry
+
+	hostname   *widget.Entry
+	domainname *widget.Entry
+	name       *widget.Entry
+	labels     *widget.Entry
+
+	buildContext *widget.Entry
+	dockerfile   *widget.Entry
+
+	composePath    *widget.Entry
+	composeService *widget.Select
+
+	commands      *widget.Entry
+	stopOnFailure *widget.Check
+
+	splitStreams   *widget.Check
+	noOutputNotice *widget.Check
+
+	forwardLocale *widget.Check
+	idleTimeout   *widget.Entry
+	maxRuntime    *widget.Entry
+
+	stopSignal  *widget.Entry
+	stopTimeout *widget.Entry
+
+	memory *widget.Entry
+	cpus   *widget.Entry
+
+	blkioWeight       *widget.Entry
+	blkioWeightDevice *widget.Entry
+	blkioReadBps      *widget.Entry
+	blkioWriteBps     *widget.Entry
+
+	oomScoreAdj    *widget.Entry
+	oomKillDisable *widget.Check
+
+	privileged    *widget.Check
+	readOnly      *widget.Check
+	keepContainer *widget.Check
+
+	volumes *widget.Entry
+	binds   *bindMountList
+	tmpfs   *widget.Entry
+
+	network    *widget.SelectEntry
+	extraHosts *widget.Entry
+	ports      *portList
+}
+
+func newOptionsForm(parent fyne.Window, prefs fyne.Preferences) *optionsForm {
+	f := &optionsForm{
+		parent: parent,
+		prefs:  prefs,
+
+		image:      widget.NewSelectEntry(prefs.StringList(prefRecentImages)),
+		command:    widget.NewMultiLineEntry(),
+		entrypoint: widget.NewEntry(),
+		env:        widget.NewMultiLineEntry(),
+		workingDir: widget.NewEntry(),
+		user:       widget.NewEntry(),
+
+		dockerHost: widget.NewEntry(),
+		tlsVerify:  widget.NewCheck("Verify the daemon's certificate (DOCKER_TLS_VERIFY)", nil),
+		tlsCACert:  widget.NewEntry(),
+		tlsCert:    widget.NewEntry(),
+		tlsKey:     widget.NewEntry(),
+		attach:     widget.NewEntry(),
+		exec:       widget.NewCheck("Run the command in it (docker exec), a shell if none is given", nil),
+		logTail:    widget.NewEntry(),
+		logSince:   widget.NewEntry(),
+
+		hostname:   widget.NewEntry(),
+		domainname: widget.NewEntry(),
+		name:       widget.NewEntry(),
+		labels:     widget.NewMultiLineEntry(),
+
+		buildContext: widget.NewEntry(),
+		dockerfile:   widget.NewEntry(),
+
+		composePath:    widget.NewEntry(),
+		composeService: widget.NewSelect(nil, nil),
+
+		commands:      widget.NewMultiLineEntry(),
+		stopOnFailure: widget.NewCheck("Stop on first failure", nil),
+
+		splitStreams:   widget.NewCheck("Split stdout/stderr panes (no TTY)", nil),
+		noOutputNotice: widget.NewCheck("Say so when a run succeeds without output", nil),
+
+		forwardLocale: widget.NewCheck("Use the host's time zone and locale", nil),
+		idleTimeout:   widget.NewEntry(),
+		maxRuntime:    widget.NewEntry(),
+
+		stopSignal:  widget.NewEntry(),
+		stopTimeout: widget.NewEntry(),
+
+		memory: widget.NewEntry(),
+		cpus:   widget.NewEntry(),
+
+		blkioWeight:       widget.NewEntry(),
+		blkioWeightDevice: widget.NewMultiLineEntry(),
+		blkioReadBps:      widget.NewMultiLineEntry(),
+		blkioWriteBps:     widget.NewMultiLineEntry(),
+
+		oomScoreAdj:    widget.NewEntry(),
+		oomKillDisable: widget.NewCheck("Disable the OOM killer", nil),
+
+		privileged:    widget.NewCheck("Privileged (all devices and capabilities)", nil),
+		readOnly:      widget.NewCheck("Read-only root filesystem, only mounts are writable", nil),
+		keepContainer: widget.NewCheck("Keep the container after it exits", nil),
+
+		volumes: widget.NewMultiLineEntry(),
+		binds:   newBindMountList(parent),
+		tmpfs:   widget.NewMultiLineEntry(),
+
+		network:    widget.NewSelectEntry(builtinNetworks),
+		extraHosts: widget.NewMultiLineEntry(),
+		ports:      newPortList(),
+	}
+	f.image.SetText(defaultImage)
+	f.image.SetPlaceHolder("e.g. alpine:latest, " + defaultImage + " runs the demo workload")
+	f.command.SetPlaceHolder(`e.g. sh -c "echo hi", the image's own command if empty`)
+	f.command.SetMinRowsVisible(2)
+	f.command.Validator = optional(func(s string) error {
+		_, err := shellSplit(s)
+		return err
+	})
+	f.entrypoint.SetPlaceHolder("the image's own entrypoint if empty")
+	f.entrypoint.Validator = f.command.Validator
+	f.env.SetPlaceHolder("NAME=VALUE, one per line")
+	f.env.SetMinRowsVisible(2)
+	f.env.Validator = optional(func(s string) error {
+		_, err := parseEnv(s)
+		return err
+	})
+	f.workingDir.SetPlaceHolder("the image's own if empty, e.g. /src")
+	f.workingDir.Validator = optional(func(s string) error {
+		if !path.IsAbs(s) {
+			return errors.New("must be an absolute path")
+		}
+		return nil
+	})
+	f.user.SetPlaceHolder("name or uid[:gid], the image's own if empty")
+	f.user.Validator = optional(validateUser)
+	f.buildContext.SetPlaceHolder("directory with a Dockerfile, runs the demo image if empty")
+	f.dockerfile.SetPlaceHolder("path in the build context, its Dockerfile if empty")
+	f.dockerfile.Validator = optional(func(s string) error {
+		if !filepath.IsLocal(s) {
+			return errors.New("must be a path inside the build context")
+		}
+		return nil
+	})
+	f.composePath.SetPlaceHolder("compose.yaml to run one service from")
+	f.composeService.PlaceHolder = "load a compose file first"
+	f.composeService.OnChanged = func(name string) {
+		if c, err := loadCompose(f.composePath.Text); err == nil && name != "" {
+			warnUnsupported(f.parent, c, name)
+		}
+	}
+	f.commands.SetPlaceHolder("one command per line, runs the demo workload if empty")
+	f.commands.SetMinRowsVisible(3)
+	f.stopOnFailure.SetChecked(true)
+	f.noOutputNotice.SetChecked(true)
+	f.dockerHost.SetPlaceHolder("e.g. tcp://build-box:2376, DOCKER_HOST or the local daemon if empty")
+	f.dockerHost.Validator = optional(validateDockerHost)
+	f.tlsCACert.SetPlaceHolder("ca.pem, the system's CAs if empty")
+	f.tlsCert.SetPlaceHolder("cert.pem, for a daemon that wants the client's")
+	f.tlsKey.SetPlaceHolder("key.pem, for the certificate")
+	for _, e := range []*widget.Entry{f.tlsCACert, f.tlsCert, f.tlsKey} {
+		e.Validator = optional(func(s string) error { return readableFile(strings.TrimSpace(s)) })
+	}
+	for _, e := range []*widget.Entry{f.dockerHost, f.tlsCACert, f.tlsCert, f.tlsKey} {
+		e.OnChanged = func(string) { f.daemonChanged() }
+	}
+	f.tlsVerify.OnChanged = func(bool) { f.daemonChanged() }
+	f.attach.SetPlaceHolder("ID or name of a running container, to attach to it instead")
+	f.logTail.SetPlaceHolder(`lines of its earlier output to show first, or "all", none if empty`)
+	f.logTail.Validator = optional(validateLogTail)
+	f.logSince.SetPlaceHolder("minutes back to show its earlier output from, no limit if empty")
+	f.logSince.Validator = optional(func(s string) error {
+		_, err := parseMinutes(s)
+		return err
+	})
+	f.hostname.SetPlaceHolder("assigned by docker")
+	f.hostname.Validator = optional(validateDNSName)
+	f.domainname.Validator = optional(validateDNSName)
+	f.name.SetPlaceHolder("made up by docker")
+	f.name.Validator = optional(validateContainerName)
+	f.labels.SetPlaceHolder("KEY=VALUE, one per line")
+	f.labels.SetMinRowsVisible(2)
+	f.labels.Validator = optional(func(s string) error {
+		_, err := parseLabels(s)
+		return err
+	})
+	f.stopSignal.SetPlaceHolder("image default, usually SIGTERM")
+	f.stopSignal.Validator = optional(func(s string) error {
+		_, err := parseSignal(s)
+		return err
+	})
+	f.stopTimeout.SetPlaceHolder("seconds to exit before it is killed, usually 10 if empty")
+	f.stopTimeout.Validator = optional(func(s string) error {
+		_, err := strconv.Atoi(s)
+		return err
+	})
+	f.idleTimeout.SetPlaceHolder("minutes without input, never if empty")
+	f.idleTimeout.Validator = optional(func(s string) error {
+		_, err := parseMinutes(s)
+		return err
+	})
+	f.maxRuntime.SetPlaceHolder("minutes running, no limit if empty")
+	f.maxRuntime.Validator = f.idleTimeout.Validator
+	f.memory.SetPlaceHolder("e.g. 512m or 2g, unlimited if empty")
+	f.memory.Validator = optional(func(s string) error {
+		_, err := parseMemory(s)
+		return err
+	})
+	f.cpus.SetPlaceHolder("e.g. 1.5, unlimited if empty")
+	f.cpus.Validator = optional(func(s string) error {
+		_, err := parseCPUs(s)
+		return err
+	})
+	f.blkioWeight.SetPlaceHolder("10 to 1000, daemon default if empty")
+	f.blkioWeightDevice.SetPlaceHolder("/dev/sda:500, one per line")
+	f.blkioReadBps.SetPlaceHolder("/dev/sda:10mb, one per line")
+	f.blkioWriteBps.SetPlaceHolder("/dev/sda:10mb, one per line")
+	for _, e := range []*widget.Entry{f.blkioWeightDevice, f.blkioReadBps, f.blkioWriteBps} {
+		e.SetMinRowsVisible(2)
+	}
+	f.oomScoreAdj.SetPlaceHolder("-1000 to 1000, daemon default if empty")
+	f.oomScoreAdj.Validator = optional(func(s string) error {
+		_, err := strconv.Atoi(s)
+		return err
+	})
+	f.oomKillDisable.OnChanged = func(on bool) {
+		if on {
+			dialog.ShowInformation("OOM Killer Disabled",
+				"Without the OOM killer a container that runs out of memory hangs instead of being killed, "+
+					"and with no memory limit it can exhaust the host's memory. Only use this with a memory limit, "+
+					"while debugging.",
+				f.parent)
+		}
+	}
+	f.volumes.SetPlaceHolder("NAME:/container/path[:ro], one per line")
+	f.volumes.SetMinRowsVisible(2)
+	f.tmpfs.SetPlaceHolder("/container/path[:OPTIONS], e.g. /tmp:size=64m, one per line")
+	f.tmpfs.SetMinRowsVisible(2)
+	f.tmpfs.Validator = optional(func(s string) error {
+		_, err := parseTmpfs(s)
+		return err
+	})
+	f.network.SetPlaceHolder("bridge, host, none or a network's name, bridge if empty")
+	f.extraHosts.SetPlaceHolder("HOST:IP, one per line")
+	f.extraHosts.SetMinRowsVisible(2)
+	f.extraHosts.Validator = optional(func(s string) error {
+		_, err := parseExtraHosts(s)
+		return err
+	})
+	f.restore()
+	return f
+}
+
+// prefFormPrefix starts the keys of the form values kept between launches.
+const prefFormPrefix = "form."
+
+// savedField is a form value kept between launches, either an entry's text or
+// a check's state.
+type savedField struct {
+	key   string
+	entry *widget.Entry
+	check *widget.Check
+}
+
+// savedFields are the values the user is most likely to want to keep. Ones
+// that warn as they are set, like disabling the OOM killer, aren't kept.
+func (f *optionsForm) savedFields() []savedField {
+	return []savedField{
+		{key: "image", entry: &f.image.Entry},
+		{key: "command", entry: f.command},
+		{key: "entrypoint", entry: f.entrypoint},
+		{key: "env", entry: f.env},
+		{key: "workingDir", entry: f.workingDir},
+		{key: "user", entry: f.user},
+		{key: "dockerHost", entry: f.dockerHost},
+		{key: "tlsCACert", entry: f.tlsCACert},
+		{key: "tlsCert", entry: f.tlsCert},
+		{key: "tlsKey", entry: f.tlsKey},
+		{key: "labels", entry: f.labels},
+		{key: "network", entry: &f.network.Entry},
+		{key: "extraHosts", entry: f.extraHosts},
+		{key: "stopOnFailure", check: f.stopOnFailure},
+		{key: "splitStreams", check: f.splitStreams},
+		{key: "noOutputNotice", check: f.noOutputNotice},
+		{key: "forwardLocale", check: f.forwardLocale},
+		{key: "privileged", check: f.privileged},
+		{key: "readOnly", check: f.readOnly},
+		{key: "tlsVerify", check: f.tlsVerify},
+		{key: "keepContainer", check: f.keepContainer},
+	}
+}
+
+// restore fills in the values saved at the end of the last session, leaving
+// the defaults for any that weren't.
+func (f *optionsForm) restore() {
+	for _, sf := range f.savedFields() {
+		if sf.entry != nil {
+			sf.entry.SetText(f.prefs.StringWithFallback(prefFormPrefix+sf.key, sf.entry.Text))
+		} else {
+			sf.check.SetChecked(f.prefs.BoolWithFallback(prefFormPrefix+sf.key, sf.check.Checked))
+		}
+	}
+}
+
+// save keeps the current values for the next launch.
+func (f *optionsForm) save() {
+	for _, sf := range f.savedFields() {
+		if sf.entry != nil {
+			f.prefs.SetString(prefFormPrefix+sf.key, sf.entry.Text)
+		} else {
+			f.prefs.SetBool(prefFormPrefix+sf.key, sf.check.Checked)
+		}
+	}
+}
+
+// widget builds the collapsible options panel shown above the terminal.
+func (f *optionsForm) widget() fyne.CanvasObject {
+	advanced := widget.NewForm(
+		widget.NewFormItem("Docker host", f.dockerHost),
+		widget.NewFormItem("TLS", f.tlsVerify),
+		widget.NewFormItem("TLS CA certificate", f.tlsCACert),
+		widget.NewFormItem("TLS certificate", f.tlsCert),
+		widget.NewFormItem("TLS key", f.tlsKey),
+		widget.NewFormItem("Container name", f.name),
+		widget.NewFormItem("Hostname", f.hostname),
+		widget.NewFormItem("Domain name", f.domainname),
+		widget.NewFormItem("Labels", f.labels),
+		widget.NewFormItem("Output", f.splitStreams),
+		widget.NewFormItem("", f.noOutputNotice),
+		widget.NewFormItem("Locale", f.forwardLocale),
+		widget.NewFormItem("Security", f.privileged),
+		widget.NewFormItem("", f.readOnly),
+		widget.NewFormItem("Cleanup", f.keepContainer),
+		widget.NewFormItem("Stop signal", f.stopSignal),
+		widget.NewFormItem("Stop grace period", f.stopTimeout),
+		widget.NewFormItem("Stop when idle", f.idleTimeout),
+		widget.NewFormItem("Max runtime", f.maxRuntime),
+	)
+	commands := widget.NewForm(
+		widget.NewFormItem("Image", f.image),
+		widget.NewFormItem("Command", f.command),
+		widget.NewFormItem("Entrypoint", f.entrypoint),
+		widget.NewFormItem("Environment", f.env),
+		widget.NewFormItem("Working directory", f.workingDir),
+		widget.NewFormItem("User", f.user),
+		widget.NewFormItem("Build context", container.NewBorder(nil, nil, nil,
+			widget.NewButton("Browse…", f.pickBuildContext), f.buildContext)),
+		widget.NewFormItem("Dockerfile", f.dockerfile),
+		widget.NewFormItem("Commands", f.commands),
+		widget.NewFormItem("", f.stopOnFailure),
+		widget.NewFormItem("Attach to", f.attach),
+		widget.NewFormItem("", f.exec),
+		widget.NewFormItem("Earlier output", f.logTail),
+		widget.NewFormItem("Earlier output since", f.logSince),
+	)
+	resources := widget.NewForm(
+		widget.NewFormItem("Memory", f.memory),
+		widget.NewFormItem("CPUs", f.cpus),
+		widget.NewFormItem("Block IO weight", f.blkioWeight),
+		widget.NewFormItem("Device IO weights", f.blkioWeightDevice),
+		widget.NewFormItem("Device read rates", f.blkioReadBps),
+		widget.NewFormItem("Device write rates", f.blkioWriteBps),
+		widget.NewFormItem("OOM score adjust", f.oomScoreAdj),
+		widget.NewFormItem("", f.oomKillDisable),
+	)
+	compose := widget.NewForm(
+		widget.NewFormItem("Compose file", container.NewBorder(nil, nil, nil,
+			widget.NewButton("Load", f.loadComposeServices), f.composePath)),
+		widget.NewFormItem("Service", f.composeService),
+	)
+	addVolume := func(name string) { appendLine(f.volumes, volumeLine(name)) }
+	storage := widget.NewForm(
+		widget.NewFormItem("Volumes", f.volumes),
+		widget.NewFormItem("", container.NewHBox(
+			widget.NewButton("Add Existing…", func() { pickVolume(f.parent, f.daemon(), addVolume) }),
+			widget.NewButton("New Volume…", func() { createVolume(f.parent, f.daemon(), addVolume) }),
+		)),
+		widget.NewFormItem("Bind mounts", f.binds.widget()),
+		widget.NewFormItem("Tmpfs", f.tmpfs),
+	)
+	network := widget.NewForm(
+		widget.NewFormItem("Network", f.network),
+		widget.NewFormItem("Extra hosts", f.extraHosts),
+		widget.NewFormItem("Ports", f.ports.widget()),
+	)
+	return widget.NewAccordion(
+		widget.NewAccordionItem("Commands", commands),
+		widget.NewAccordionItem("Compose", compose),
+		widget.NewAccordionItem("Resources", resources),
+		widget.NewAccordionItem("Storage", storage),
+		widget.NewAccordionItem("Network", network),
+		widget.NewAccordionItem("Advanced", advanced),
+	)
+}
+
+// options reads the current values out of the form, it must be called from the
+// UI goroutine.
+func (f *optionsForm) options() (runOptions, error) {
+	opts := runOptions{
+		DockerHost: f.host(),
+		DockerTLS:  f.tls(),
+		Attach:     strings.TrimSpace(f.attach.Text),
+		Exec:       f.exec.Checked,
+		LogTail:    strings.TrimSpace(f.logTail.Text),
+
+		Hostname:   f.hostname.Text,
+		Domainname: f.domainname.Text,
+		Name:       strings.TrimSpace(f.name.Text),
+
+		WorkingDir: strings.TrimSpace(f.workingDir.Text),
+		User:       strings.TrimSpace(f.user.Text),
+
+		Image:        strings.TrimSpace(f.image.Text),
+		BuildContext: strings.TrimSpace(f.buildContext.Text),
+		Dockerfile:   strings.TrimSpace(f.dockerfile.Text),
+
+		Commands:      nonEmptyLines(f.commands.Text),
+		StopOnFailure: f.stopOnFailure.Checked,
+
+		SplitStreams:   f.splitStreams.Checked,
+		NoOutputNotice: f.noOutputNotice.Checked,
+
+		ForwardLocale: f.forwardLocale.Checked,
+
+		StopSignal: strings.TrimSpace(f.stopSignal.Text),
+	}
+	var err error
+	if opts.Cmd, err = shellSplit(f.command.Text); err != nil {
+		return opts, fmt.Errorf("invalid command: %w", err)
+	}
+	if opts.Entrypoint, err = shellSplit(f.entrypoint.Text); err != nil {
+		return opts, fmt.Errorf("invalid entrypoint: %w", err)
+	}
+	if opts.Env, err = parseEnv(f.env.Text); err != nil {
+		return opts, fmt.Errorf("invalid environment: %w", err)
+	}
+	if opts.Labels, err = parseLabels(f.labels.Text); err != nil {
+		return opts, fmt.Errorf("invalid labels: %w", err)
+	}
+	if opts.StopTimeout, err = optionalInt(f.stopTimeout.Text); err != nil {
+		return opts, fmt.Errorf("invalid stop timeout: %w", err)
+	}
+	if t := strings.TrimSpace(f.idleTimeout.Text); t != "" {
+		if opts.IdleTimeout, err = parseMinutes(t); err != nil {
+			return opts, fmt.Errorf("invalid idle timeout: %w", err)
+		}
+	}
+	if t := strings.TrimSpace(f.logSince.Text); t != "" {
+		if opts.LogSince, err = parseMinutes(t); err != nil {
+			return opts, fmt.Errorf("invalid earlier output period: %w", err)
+		}
+	}
+	if t := strings.TrimSpace(f.maxRuntime.Text); t != "" {
+		if opts.MaxRuntime, err = parseMinutes(t); err != nil {
+			return opts, fmt.Errorf("invalid max runtime: %w", err)
+		}
+	}
+	if m := strings.TrimSpace(f.memory.Text); m != "" {
+		if opts.Memory, err = parseMemory(m); err != nil {
+			return opts, fmt.Errorf("invalid memory limit: %w", err)
+		}
+	}
+	if c := strings.TrimSpace(f.cpus.Text); c != "" {
+		if opts.NanoCPUs, err = parseCPUs(c); err != nil {
+			return opts, fmt.Errorf("invalid CPU limit: %w", err)
+		}
+	}
+	if w := strings.TrimSpace(f.blkioWeight.Text); w != "" {
+		n, err := strconv.ParseUint(w, 10, 16)
+		if err != nil {
+			return opts, fmt.Errorf("invalid block IO weight: %w", err)
+		}
+		opts.BlkioWeight = uint16(n)
+	}
+	if opts.BlkioWeightDevice, err = parseWeightDevices(f.blkioWeightDevice.Text); err != nil {
+		return opts, fmt.Errorf("invalid device IO weight: %w", err)
+	}
+	if opts.BlkioDeviceReadBps, err = parseThrottleDevices(f.blkioReadBps.Text); err != nil {
+		return opts, fmt.Errorf("invalid device read rate: %w", err)
+	}
+	if opts.BlkioDeviceWriteBps, err = parseThrottleDevices(f.blkioWriteBps.Text); err != nil {
+		return opts, fmt.Errorf("invalid device write rate: %w", err)
+	}
+	if opts.OomScoreAdj, err = optionalInt(f.oomScoreAdj.Text); err != nil {
+		return opts, fmt.Errorf("invalid OOM score adjustment: %w", err)
+	}
+	opts.OomKillDisable = f.oomKillDisable.Checked
+	opts.Privileged = f.privileged.Checked
+	opts.ReadonlyRootfs = f.readOnly.Checked
+	opts.KeepContainer = f.keepContainer.Checked
+	if opts.Volumes, err = parseVolumes(f.volumes.Text); err != nil {
+		return opts, fmt.Errorf("invalid volume: %w", err)
+	}
+	binds, err := f.binds.mounts()
+	if err != nil {
+		return opts, fmt.Errorf("invalid bind mount: %w", err)
+	}
+	opts.Volumes = append(opts.Volumes, binds...)
+	if opts.Tmpfs, err = parseTmpfs(f.tmpfs.Text); err != nil {
+		return opts, fmt.Errorf("invalid tmpfs: %w", err)
+	}
+	opts.Network = strings.TrimSpace(f.network.Text)
+	if opts.ExtraHosts, err = parseExtraHosts(f.extraHosts.Text); err != nil {
+		return opts, fmt.Errorf("invalid extra hosts: %w", err)
+	}
+	if opts.ExposedPorts, opts.PortBindings, err = f.ports.bindings(); err != nil {
+		return opts, fmt.Errorf("invalid port: %w", err)
+	}
+	if path, name := strings.TrimSpace(f.composePath.Text), f.composeService.Selected; path != "" && name != "" {
+		// read it again, in case it has been edited since it was loaded
+		c, err := loadCompose(path)
+		if err != nil {
+			return opts, err
+		}
+		svc, _, err := c.service(name)
+		if err == nil {
+			err = svc.apply(&opts, c.dir)
+		}
+		if err != nil {
+			return opts, fmt.Errorf("unable to run service %s: %w", name, err)
+		}
+	}
+	if opts.Image == "" && opts.BuildContext == "" && opts.Attach == "" {
+		return opts, errors.New("no image given, enter one to run or a build context")
+	}
+	return opts, nil
+}
+
+// rememberImage adds image to the top of the recently run ones offered.
+func (f *optionsForm) rememberImage(image string) {
+	recent := []string{image}
+	for _, r := range f.prefs.StringList(prefRecentImages) {
+		if r != image && len(recent) < maxRecentImages {
+			recent = append(recent, r)
+		}
+	}
+	f.prefs.SetStringList(prefRecentImages, recent)
+	f.image.SetOptions(recent)
+}
+
+// host is the docker daemon to use, empty for the environment's.
+func (f *optionsForm) host() string {
+	return strings.TrimSpace(f.dockerHost.Text)
+}
+
+// tls is how to connect to the daemon over TLS, the zero value for the
+// environment's settings.
+func (f *optionsForm) tls() dockerTLS {
+	return dockerTLS{
+		Verify: f.tlsVerify.Checked,
+		CACert: strings.TrimSpace(f.tlsCACert.Text),
+		Cert:   strings.TrimSpace(f.tlsCert.Text),
+		Key:    strings.TrimSpace(f.tlsKey.Text),
+	}
+}
+
+// daemon is host and tls together, for connecting to the daemon from the
+// form rather than a run.
+func (f *optionsForm) daemon() dockerDaemon {
+	return dockerDaemon{host: f.host(), tls: f.tls()}
+}
+
+func (f *optionsForm) daemonChanged() {
+	if f.onDaemonChanged != nil {
+		f.onDaemonChanged()
+	}
+}
+
+// pickBuildContext fills in the build context from a folder chosen in a dialog.
+func (f *optionsForm) pickBuildContext() {
+	dialog.ShowFolderOpen(func(dir fyne.ListableURI, err error) {
+		if err != nil {
+			dialog.ShowError(err, f.parent)
+			return
+		}
+		if dir == nil {
+			return
+		}
+		f.buildContext.SetText(dir.Path())
+	}, f.parent)
+}
+
+// loadComposeServices offers the services in the compose file to choose from.
+func (f *optionsForm) loadComposeServices() {
+	c, err := loadCompose(strings.TrimSpace(f.composePath.Text))
+	if err != nil {
+		dialog.ShowError(err, f.parent)
+		return
+	}
+	f.composeService.ClearSelected()
+	f.composeService.SetOptions(c.names())
+	if len(c.services) == 1 {
+		f.composeService.SetSelectedIndex(0)
+	}
+}
+
+// optionalInt parses s as an integer, returning nil if it is blank.
+func optionalInt(s string) (*int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// parseMinutes parses a positive, possibly fractional, number of minutes.
+func parseMinutes(s string) (time.Duration, error) {
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, errors.New("must be more than 0")
+	}
+	return time.Duration(n * float64(time.Minute)), nil
+}
+
+// nonEmptyLines splits text into lines, dropping any that are blank.
+func nonEmptyLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// optional adapts a validator so that it accepts empty input.
+func optional(validate func(string) error) fyne.StringValidator {
+	return func(s string) error {
+		if s == "" {
+			return nil
+		}
+		return validate(s)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	dockerContainer "github.com/docker/docker/api/types/container"
+)
+
+const (
+	// healthPoll is how often a container with a health check is inspected
+	// until it is healthy.
+	healthPoll = time.Second
+	// healthWait is how long it has to become healthy before the status says
+	// it hasn't.
+	healthWait = 5 * time.Minute
+)
+
+// waitHealthy follows the health check of a started container, only saying it
+// is running once the check first passes, or why it is failing if it doesn't.
+// It gives up when ended is closed, as the container has exited.
+func waitHealthy(ctx context.Context, dc dockerClient, id string, ended <-chan struct{}, hooks runHooks) error {
+	hooks.status("Running, waiting for the health check", false)
+	poll := time.NewTicker(healthPoll)
+	defer poll.Stop()
+	deadline := time.After(healthWait)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ended:
+			return nil
+		case <-deadline:
+			hooks.status(fmt.Sprintf("Running, but not healthy after %v", healthWait), true)
+			return nil
+		case <-poll.C:
+		}
+		info, err := dc.ContainerInspect(ctx, id)
+		if err != nil || info.State == nil || info.State.Health == nil {
+			// it will have exited, or this will show on the next poll
+			continue
+		}
+		switch health := info.State.Health; health.Status {
+		case dockerContainer.Healthy:
+			hooks.status("Running, healthy", false)
+			return nil
+		case dockerContainer.Unhealthy:
+			hooks.status("Running, but unhealthy: "+lastHealthOutput(health), true)
+			return nil
+		}
+	}
+}
+
+// lastHealthOutput is what the most recent health check printed, or its
+// exit code if nothing.
+func lastHealthOutput(h *dockerContainer.Health) string {
+	if len(h.Log) == 0 {
+		return "no checks recorded"
+	}
+	last := h.Log[len(h.Log)-1]
+	if out := strings.Join(strings.Fields(last.Output), " "); out != "" {
+		return out
+	}
+	return fmt.Sprintf("check exited with code %d", last.ExitCode)
+}
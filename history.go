@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// historyEntry is one saved run profile.
+type historyEntry struct {
+	path    string
+	profile runProfile
+}
+
+// summary describes the run in a single line for the history list.
+func (e historyEntry) summary() string {
+	what := "demo workload"
+	if len(e.profile.Options.Commands) > 0 {
+		what = strings.Join(e.profile.Options.Commands, "; ")
+	}
+	line := fmt.Sprintf("%s  %s  %s", e.profile.SavedAt.Format("2006-01-02 15:04:05"), e.profile.Image, what)
+	if e.profile.Note != "" {
+		line += "  [" + e.profile.Note + "]"
+	}
+	return line
+}
+
+func (e historyEntry) matches(query string) bool {
+	query = strings.ToLower(query)
+	return strings.Contains(strings.ToLower(e.profile.Note), query) ||
+		strings.Contains(strings.ToLower(e.summary()), query)
+}
+
+// loadHistory reads the run profiles saved in dir, newest first.
+func loadHistory(dir string) ([]historyEntry, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "run-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var entries []historyEntry
+	for _, f := range files {
+		p, err := readProfile(f)
+		if err != nil {
+			fyne.LogError("skipping unreadable run profile "+f, err)
+			continue
+		}
+		entries = append(entries, historyEntry{path: f, profile: p})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].profile.SavedAt.After(entries[j].profile.SavedAt)
+	})
+	return entries, nil
+}
+
+func readProfile(path string) (runProfile, error) {
+	var p runProfile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	return p, json.Unmarshal(data, &p)
+}
+
+// rewriteProfile replaces a saved profile, such that a crash part way through
+// leaves either the old or the new one.
+func rewriteProfile(path string, p runProfile) error {
+	data, err := marshalProfile(p)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// showHistory opens a window listing the saved runs, where each one can be
+// given a note and the list searched by them.
+func (s *AppState) showHistory() {
+	dir := profileDir(s.app.Preferences())
+	all, err := loadHistory(dir)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("unable to read run history: %w", err), s.mainWindow)
+		return
+	}
+
+	w := s.app.NewWindow("Run History")
+	shown := all
+	selected := -1
+
+	note := widget.NewEntry()
+	note.SetPlaceHolder("select a run to add a note, e.g. repro for bug X")
+	note.Disable()
+	list := widget.NewList(
+		func() int { return len(shown) },
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("")
+			l.Truncation = fyne.TextTruncateEllipsis
+			return l
+		},
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(shown[id].summary())
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		selected = id
+		note.SetText(shown[id].profile.Note)
+		note.Enable()
+	}
+	list.OnUnselected = func(widget.ListItemID) {
+		selected = -1
+		note.SetText("")
+		note.Disable()
+	}
+
+	search := widget.NewEntry()
+	search.SetPlaceHolder("search notes and runs")
+	search.OnChanged = func(q string) {
+		shown = shown[:0:0]
+		for _, e := range all {
+			if e.matches(q) {
+				shown = append(shown, e)
+			}
+		}
+		list.UnselectAll()
+		list.Refresh()
+	}
+
+	saveNote := func() {
+		if selected < 0 {
+			return
+		}
+		e := &shown[selected]
+		e.profile.Note = strings.TrimSpace(note.Text)
+		if err := rewriteProfile(e.path, e.profile); err != nil {
+			dialog.ShowError(fmt.Errorf("unable to save note: %w", err), w)
+			return
+		}
+		for i := range all {
+			if all[i].path == e.path {
+				all[i].profile.Note = e.profile.Note
+			}
+		}
+		list.RefreshItem(selected)
+	}
+	note.OnSubmitted = func(string) { saveNote() }
+
+	empty := widget.NewLabel("No runs have been saved yet, turn on File > Run Profiles to record them.")
+	empty.Hidden = len(all) > 0
+	w.SetContent(container.NewBorder(
+		container.NewVBox(search, empty),
+		container.NewBorder(nil, nil, widget.NewLabel("Note"), widget.NewButton("Save", saveNote), note),
+		nil, nil,
+		list,
+	))
+	w.Resize(fyne.NewSize(800, 500))
+	w.Show()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+// idleWarning is how long before an idle session is stopped the user is
+// warned about it, at most.
+const idleWarning = time.Minute
+
+// inputTracker records when input last passed through it.
+type inputTracker struct {
+	r    io.Reader
+	last atomic.Int64 // UnixNano
+}
+
+func newInputTracker(r io.Reader) *inputTracker {
+	t := &inputTracker{r: r}
+	t.last.Store(time.Now().UnixNano())
+	return t
+}
+
+func (t *inputTracker) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.last.Store(time.Now().UnixNano())
+	}
+	return n, err
+}
+
+func (t *inputTracker) idleFor(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, t.last.Load()))
+}
+
+// watchIdle calls stop once there has been no input for timeout, counting
+// down the last part of it in the idle label. Typing anything resets it.
+func (s *session) watchIdle(ctx context.Context, input *inputTracker, timeout time.Duration, stop func()) {
+	warning := min(idleWarning, timeout/4)
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	defer fyne.Do(func() { s.idleLabel.SetText("") })
+	shown := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-tick.C:
+			remaining := timeout - input.idleFor(now)
+			switch {
+			case remaining <= 0:
+				stop()
+				return
+			case remaining <= warning:
+				text := fmt.Sprintf("Idle, stopping in %ds, type to keep it running", int(remaining.Round(time.Second)/time.Second))
+				fyne.Do(func() { s.idleLabel.SetText(text) })
+				shown = true
+			case shown:
+				fyne.Do(func() { s.idleLabel.SetText("") })
+				shown = false
+			}
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+const keymapPrefPrefix = "keymap."
+
+// keyBinding is a key plus the modifiers that must be held with it.
+type keyBinding struct {
+	Key      fyne.KeyName
+	Modifier fyne.KeyModifier
+}
+
+var modifierNames = []struct {
+	mod  fyne.KeyModifier
+	name string
+}{
+	{fyne.KeyModifierControl, "Ctrl"},
+	{fyne.KeyModifierAlt, "Alt"},
+	{fyne.KeyModifierSuper, "Super"},
+	{fyne.KeyModifierShift, "Shift"},
+}
+
+// String formats the binding like "Ctrl+Shift+R", which parseKeyBinding
+// accepts.
+func (b keyBinding) String() string {
+	var parts []string
+	for _, m := range modifierNames {
+		if b.Modifier&m.mod != 0 {
+			parts = append(parts, m.name)
+		}
+	}
+	return strings.Join(append(parts, string(b.Key)), "+")
+}
+
+func (b keyBinding) shortcut() *desktop.CustomShortcut {
+	return &desktop.CustomShortcut{KeyName: b.Key, Modifier: b.Modifier}
+}
+
+func parseKeyBinding(s string) (keyBinding, error) {
+	s = strings.TrimSpace(s)
+	var b keyBinding
+	if s == "" {
+		return b, errors.New("empty key binding")
+	}
+	// allow "+" itself to be the key, as in "Ctrl++"
+	key := s
+	if i := strings.LastIndex(s[:len(s)-1], "+"); i >= 0 {
+		key = s[i+1:]
+		for _, name := range strings.Split(s[:i], "+") {
+			found := false
+			for _, m := range modifierNames {
+				if strings.EqualFold(name, m.name) {
+					b.Modifier |= m.mod
+					found = true
+				}
+			}
+			if !found {
+				return b, fmt.Errorf("unknown modifier %q", name)
+			}
+		}
+	}
+	if len(key) == 1 {
+		key = strings.ToUpper(key)
+	}
+	b.Key = fyne.KeyName(key)
+	return b, b.validate()
+}
+
+// validate checks that the binding won't take away input the terminal needs to
+// pass on to the container.
+func (b keyBinding) validate() error {
+	if b.Key == "" {
+		return errors.New("no key given")
+	}
+	if b.Modifier&^fyne.KeyModifierShift == 0 {
+		return fmt.Errorf("%s needs Ctrl, Alt or Super, it would be typed into the terminal", b)
+	}
+	if b.Modifier == fyne.KeyModifierControl && isControlCharKey(b.Key) {
+		return fmt.Errorf("%s is sent to the container as a control character, try adding Shift", b)
+	}
+	return nil
+}
+
+// isControlCharKey reports whether Ctrl+key produces a C0 control character in
+// the terminal.
+func isControlCharKey(key fyne.KeyName) bool {
+	if key == fyne.KeySpace {
+		return true
+	}
+	return len(key) == 1 && (key[0] >= 'A' && key[0] <= '_' || key[0] == '@')
+}
+
+// shortcutRegistry is implemented by both fyne.Canvas and the terminal
+// widget's fyne.ShortcutHandler.
+type shortcutRegistry interface {
+	AddShortcut(shortcut fyne.Shortcut, handler func(shortcut fyne.Shortcut))
+	RemoveShortcut(shortcut fyne.Shortcut)
+}
+
+type keyAction struct {
+	id, label string
+	def       keyBinding
+	bound     keyBinding
+	run       func()
+}
+
+// keymap binds actions to shortcuts, persisting any changes the user makes in
+// the app preferences.
+//
+// The bindings must be registered with the focusable terminal widgets as well
+// as the window canvas, as the canvas only sees shortcuts that the focused
+// widget doesn't handle itself. Note the terminal widget registers its own
+// Ctrl+Shift+C/V copy and paste when it is first rendered, which replace ours
+// for those exact bindings but do the same thing.
+type keymap struct {
+	prefs      fyne.Preferences
+	registries []shortcutRegistry
+	actions    []*keyAction
+}
+
+func newKeymap(prefs fyne.Preferences, registries ...shortcutRegistry) *keymap {
+	return &keymap{prefs: prefs, registries: registries}
+}
+
+// add registers an action, bound either to its saved binding or to def.
+func (k *keymap) add(id, label string, def keyBinding, run func()) {
+	a := &keyAction{id: id, label: label, def: def, bound: def, run: run}
+	if saved := k.prefs.String(keymapPrefPrefix + id); saved != "" {
+		if b, err := parseKeyBinding(saved); err == nil {
+			a.bound = b
+		} else {
+			fyne.LogError("ignoring saved binding for "+id, err)
+		}
+	}
+	k.actions = append(k.actions, a)
+	k.register(a)
+}
+
+// addRegistries registers every action with more registries, e.g. the
+// terminals of a new session.
+func (k *keymap) addRegistries(rs ...shortcutRegistry) {
+	k.registries = append(k.registries, rs...)
+	for _, a := range k.actions {
+		for _, r := range rs {
+			r.AddShortcut(a.bound.shortcut(), func(fyne.Shortcut) { a.run() })
+		}
+	}
+}
+
+// removeRegistries forgets registries that are going away, leaving their
+// shortcuts as they are.
+func (k *keymap) removeRegistries(rs ...shortcutRegistry) {
+	k.registries = slices.DeleteFunc(k.registries, func(r shortcutRegistry) bool {
+		return slices.Contains(rs, r)
+	})
+}
+
+func (k *keymap) register(a *keyAction) {
+	for _, r := range k.registries {
+		r.AddShortcut(a.bound.shortcut(), func(fyne.Shortcut) { a.run() })
+	}
+}
+
+func (k *keymap) unregister(a *keyAction) {
+	for _, r := range k.registries {
+		r.RemoveShortcut(a.bound.shortcut())
+	}
+}
+
+// conflicts returns a description of each binding used for more than one of
+// the actions.
+func conflicts(actions []*keyAction, bindings []keyBinding) []string {
+	var found []string
+	for i := range bindings {
+		for j := i + 1; j < len(bindings); j++ {
+			if bindings[i] == bindings[j] {
+				found = append(found, fmt.Sprintf(
+					"%s is bound to both %q and %q", bindings[i], actions[i].label, actions[j].label))
+			}
+		}
+	}
+	return found
+}
+
+// apply replaces all bindings at once, so that swapping two bindings works.
+func (k *keymap) apply(bindings []keyBinding) error {
+	if c := conflicts(k.actions, bindings); len(c) > 0 {
+		return errors.New(strings.Join(c, "\n"))
+	}
+	for _, a := range k.actions {
+		k.unregister(a)
+	}
+	for i, a := range k.actions {
+		a.bound = bindings[i]
+		if a.bound == a.def {
+			k.prefs.RemoveValue(keymapPrefPrefix + a.id)
+		} else {
+			k.prefs.SetString(keymapPrefPrefix+a.id, a.bound.String())
+		}
+		k.register(a)
+	}
+	return nil
+}
+
+// showDialog lets the user edit the bindings, and the detach keys typed into
+// the terminal itself.
+func (k *keymap) showDialog(parent fyne.Window) {
+	entries := make([]*widget.Entry, len(k.actions))
+	items := make([]*widget.FormItem, 0, len(k.actions)+2)
+	for i, a := range k.actions {
+		e := widget.NewEntry()
+		e.SetText(a.bound.String())
+		e.Validator = func(s string) error {
+			_, err := parseKeyBinding(s)
+			return err
+		}
+		entries[i] = e
+		items = append(items, widget.NewFormItem(a.label, e))
+	}
+	detach := widget.NewEntry()
+	detach.SetText(k.prefs.StringWithFallback(prefDetachKeys, defaultDetachKeys))
+	detach.Validator = func(s string) error {
+		_, err := parseDetachKeys(s)
+		return err
+	}
+	detachItem := widget.NewFormItem("Detach from container", detach)
+	detachItem.HintText = "typed in the terminal, as docker's --detach-keys"
+	items = append(items, detachItem)
+	items = append(items, widget.NewFormItem("", widget.NewButton("Reset to defaults", func() {
+		for i, a := range k.actions {
+			entries[i].SetText(a.def.String())
+		}
+		detach.SetText(defaultDetachKeys)
+	})))
+
+	d := dialog.NewForm("Keyboard Shortcuts", "Save", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		bindings := make([]keyBinding, len(entries))
+		for i, e := range entries {
+			// the form won't submit unless every entry validates
+			bindings[i], _ = parseKeyBinding(e.Text)
+		}
+		if err := k.apply(bindings); err != nil {
+			dialog.ShowError(fmt.Errorf("shortcuts not saved: %w", err), parent)
+		}
+		if detach.Text == defaultDetachKeys {
+			k.prefs.RemoveValue(prefDetachKeys)
+		} else {
+			k.prefs.SetString(prefDetachKeys, detach.Text)
+		}
+	}, parent)
+	d.Resize(fyne.NewSize(400, 0))
+	d.Show()
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+)
+
+// maxLifecycleLog is the size past which a lifecycle log file is moved aside,
+// to path.1, when the app starts.
+const maxLifecycleLog = 10 << 20
+
+// lifecycleLog records each step of a run, for debugging the app itself. It
+// discards everything unless openLifecycleLog is called.
+var lifecycleLog = slog.New(slog.DiscardHandler)
+
+// openLifecycleLog starts writing the lifecycle log to path, or to stderr for
+// "-", returning how to close it.
+func openLifecycleLog(path string) (func() error, error) {
+	if path == "-" {
+		lifecycleLog = slog.New(slog.NewTextHandler(os.Stderr, nil))
+		return func() error { return nil }, nil
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() > maxLifecycleLog {
+		if err := os.Rename(path, path+".1"); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	lifecycleLog = slog.New(slog.NewTextHandler(f, nil))
+	return f.Close, nil
+}
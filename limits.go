@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	dockerContainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/go-units"
+)
+
+// minMemory is the smallest memory limit the daemon accepts.
+const minMemory = 6 << 20
+
+// activeContainer is the container of the current run, once it is created.
+type activeContainer struct {
+	dc dockerClient
+	id string
+}
+
+// setActive records the current run's container, or nil when it is gone, and
+// enables the controls that act on it.
+func (s *session) setActive(c *activeContainer) {
+	s.activeMu.Lock()
+	s.active = c
+	s.activeMu.Unlock()
+	fyne.Do(func() {
+		if c != nil {
+			s.limitsButton.Enable()
+		} else {
+			s.limitsButton.Disable()
+		}
+	})
+}
+
+func (s *session) currentContainer() *activeContainer {
+	s.activeMu.Lock()
+	defer s.activeMu.Unlock()
+	return s.active
+}
+
+// parseCPUs parses a number of CPUs such as 1.5 into the NanoCPUs the daemon
+// expects.
+func parseCPUs(s string) (int64, error) {
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, errors.New("must be more than 0")
+	}
+	return int64(n * 1e9), nil
+}
+
+func parseMemory(s string) (int64, error) {
+	n, err := units.RAMInBytes(strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	if n < minMemory {
+		return 0, fmt.Errorf("must be at least %s", units.BytesSize(minMemory))
+	}
+	return n, nil
+}
+
+// showUpdateLimits lets the user change the CPU and memory limits of the
+// running container, applying them with ContainerUpdate.
+func (s *session) showUpdateLimits() {
+	c := s.currentContainer()
+	if c == nil {
+		return
+	}
+	cpus := widget.NewEntry()
+	cpus.SetPlaceHolder("e.g. 1.5, unchanged if empty")
+	cpus.Validator = optional(func(s string) error {
+		_, err := parseCPUs(s)
+		return err
+	})
+	memory := widget.NewEntry()
+	memory.SetPlaceHolder("e.g. 512m, unchanged if empty")
+	memory.Validator = optional(func(s string) error {
+		_, err := parseMemory(s)
+		return err
+	})
+	dialog.ShowForm("Update Limits", "Apply", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("CPUs", cpus),
+		widget.NewFormItem("Memory", memory),
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+		// the form won't submit unless the entries validate
+		var update dockerContainer.UpdateConfig
+		if cpus.Text != "" {
+			update.NanoCPUs, _ = parseCPUs(cpus.Text)
+		}
+		if memory.Text != "" {
+			update.Memory, _ = parseMemory(memory.Text)
+		}
+		if update.NanoCPUs == 0 && update.Memory == 0 {
+			return
+		}
+		go func() {
+			resp, err := c.dc.ContainerUpdate(context.Background(), c.id, update)
+			fyne.Do(func() {
+				if err != nil {
+					dialog.ShowError(fmt.Errorf("unable to update limits: %w", err), s.mainWindow)
+					return
+				}
+				msg := "The new limits have been applied."
+				if len(resp.Warnings) > 0 {
+					msg += "\n\n" + strings.Join(resp.Warnings, "\n")
+				}
+				dialog.ShowInformation("Limits Updated", msg, s.mainWindow)
+			})
+		}()
+	}, s.mainWindow)
+}
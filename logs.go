@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	dockerContainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// showEarlierOutput writes what the container id wrote before until, as much
+// of it as opts.LogTail and opts.LogSince ask for, to stdout and stderr as
+// attaching to it would. A nil stderr means it has a TTY. It does nothing if
+// neither is set.
+//
+// until is when the attach that follows was made, so that what it streams
+// isn't shown twice. Log times are the daemon's, so that only holds as far as
+// its clock agrees with ours.
+func showEarlierOutput(
+	ctx context.Context,
+	dc dockerClient,
+	id string,
+	opts runOptions,
+	until time.Time,
+	stdout, stderr io.Writer,
+) error {
+	if opts.LogTail == "" && opts.LogSince == 0 {
+		return nil
+	}
+	logOpts := dockerContainer.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       opts.LogTail,
+		Until:      until.Format(time.RFC3339Nano),
+	}
+	if opts.LogSince > 0 {
+		logOpts.Since = until.Add(-opts.LogSince).Format(time.RFC3339Nano)
+	}
+	logs, err := dc.ContainerLogs(ctx, id, logOpts)
+	if err != nil {
+		return fmt.Errorf("unable to get the container's earlier output: %w", err)
+	}
+	defer logs.Close()
+	if stderr == nil {
+		_, err = copyBuffered(stdout, logs)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, logs)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to get the container's earlier output: %w", err)
+	}
+	return nil
+}
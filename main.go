@@ -3,12 +3,16 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"os"
 	"os/signal"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -16,18 +20,49 @@ import (
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
-	"fyne.io/fyne/v2/theme"
-	"fyne.io/fyne/v2/widget"
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/docker/docker/api/types"
 	dockerContainer "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/fyne-io/terminal"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sys/unix"
 )
 
 func main() {
+	os.Exit(appMain())
+}
+
+// appMain is main, returning the exit status so that deferred cleanup still
+// happens.
+func appMain() int {
+	serve := flag.String("serve", "",
+		"also serve the terminal, input included, to browsers at this `address`, e.g. 127.0.0.1:8022")
+	lifecycle := flag.String("lifecycle-log", "",
+		"log each step of a run to this `file`, or - for stderr, to debug the app")
+	exitWithRun := flag.Bool("exit-with-run", false,
+		"start a run with the saved options at once, then quit with the container's exit code once it is over")
+	flag.Parse()
+	command, err := parseCommand(flag.Args(), os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+	if *lifecycle != "" {
+		closeLog, err := openLifecycleLog(*lifecycle)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to open lifecycle log: %v\n", err)
+			return 2
+		}
+		defer closeLog()
+	}
+
 	ctx, stop := context.WithCancel(context.Background())
 	defer stop()
 
@@ -41,62 +76,306 @@ func main() {
 		app: a,
 	}
 	s.createMainWindow()
+	if *serve != "" {
+		// browsers are shown the session the app starts with
+		first := s.sessions[0]
+		first.viewers = newViewerHub()
+		go func() {
+			if err := first.serveViewers(sigCtx, *serve); err != nil {
+				fyne.LogError("viewer server failed", err)
+			}
+		}()
+	}
+
+	if *exitWithRun || command != nil {
+		first := s.sessions[0]
+		first.exitWhenDone = func(exitCode int, err error) {
+			if command != nil {
+				// as it was shown, for the output to be of use to a script
+				if err := first.transcript.save(os.Stdout, true); err != nil {
+					fmt.Fprintf(os.Stderr, "unable to print output: %v\n", err)
+				}
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "run failed: %v\n", err)
+			}
+			fyne.Do(func() {
+				s.exitStatus = runExitStatus(exitCode, err)
+				s.app.Quit()
+			})
+		}
+		if command != nil {
+			s.app.Lifecycle().SetOnStarted(func() { first.start(*command) })
+		} else {
+			s.app.Lifecycle().SetOnStarted(first.run)
+		}
+	}
 
 	s.mainWindow.Show()
+	watchMinimised(s.mainWindow, func(minimised bool) {
+		s.minimised = minimised
+		s.pauseUnseen()
+	})
 	s.app.Run()
+	for _, sess := range s.sessions {
+		sess.transcript.close()
+		sess.recording.close()
+	}
+	return s.exitStatus
 }
 
 type AppState struct {
 	ctx        context.Context
 	app        fyne.App
 	mainWindow fyne.Window
-	terminal   *terminal.Terminal
-	termSize   *termSizeTracker
+	options    *optionsForm
+
+	// fontTheme sets the text size of every session's terminals
+	fontTheme *fontSizeTheme
+
+	keymap *keymap
+
+	// tabs holds a tab for each of the sessions, which are only used, like
+	// nextSession numbering them, from the UI goroutine
+	tabs        *container.DocTabs
+	sessions    []*session
+	nextSession int
+
+	// daemonTimer checks the daemon again once its settings stop changing,
+	// it is only used from the UI goroutine
+	daemonTimer *time.Timer
+
+	// minimised is set while the main window is, only used from the UI
+	// goroutine
+	minimised bool
+
+	// exitStatus is what the app exits with, see runExitStatus
+	exitStatus int
 }
 
 func (s *AppState) createMainWindow() {
-	w := s.app.NewWindow("Slow Terminal Demo")
+	w := s.app.NewWindow(appTitle)
 	s.mainWindow = w
+	s.options = newOptionsForm(w, s.app.Preferences())
+	s.options.onDaemonChanged = s.daemonChanged
+	s.fontTheme = newFontSizeTheme(s.app.Preferences())
+	s.keymap = newKeymap(s.app.Preferences(), w.Canvas())
+	s.tabs = container.NewDocTabs()
+	s.tabs.CreateTab = func() *container.TabItem { return s.newSession().tab }
+	s.tabs.CloseIntercept = func(item *container.TabItem) { s.closeSession(s.sessionFor(item)) }
+	s.tabs.OnSelected = func(item *container.TabItem) {
+		if sess := s.sessionFor(item); sess != nil {
+			sess.refreshTitle()
+		}
+		s.pauseUnseen()
+	}
+	s.addSession()
 
 	content := container.NewBorder(
-		// top
-		widget.NewButtonWithIcon("Run!", theme.DownloadIcon(), s.run),
-		nil, // bottom
-		nil, // left
-		nil, // right
-		// center
-		newTerminal(s),
+		s.options.widget(), // top
+		nil,                // bottom
+		nil,                // left
+		nil,                // right
+		s.tabs,             // center
 	)
 
+	s.addActions()
+	w.SetMainMenu(fyne.NewMainMenu(
+		fyne.NewMenu("File",
+			fyne.NewMenuItem("New Session", s.addSession),
+			fyne.NewMenuItemSeparator(),
+			fyne.NewMenuItem("Save Screenshot…", func() { s.current().saveScreenshot() }),
+			fyne.NewMenuItem("Save Output…", func() { s.current().saveOutput() }),
+			fyne.NewMenuItem("Save Recording…", func() { s.current().saveRecording() }),
+			fyne.NewMenuItem("Replay Recording…", func() { s.current().replay() }),
+			fyne.NewMenuItemSeparator(),
+			fyne.NewMenuItem("Output Settings…", s.showOutputSettings),
+			fyne.NewMenuItem("Run Profiles…", s.showProfileSettings),
+			fyne.NewMenuItem("Run History…", s.showHistory),
+			fyne.NewMenuItem("Remove Leftover Containers…", s.cleanupContainers),
+			fyne.NewMenuItem("Keyboard Shortcuts…", func() { s.keymap.showDialog(w) }),
+		),
+		fyne.NewMenu("View",
+			fyne.NewMenuItem("Bigger Text", s.growFont),
+			fyne.NewMenuItem("Smaller Text", s.shrinkFont),
+			fyne.NewMenuItem("Default Text Size", func() { s.setFontSize(0) }),
+		),
+		fyne.NewMenu("Container",
+			s.signalMenu(),
+		),
+	))
+
 	w.SetContent(content)
 	w.SetMaster()
-	w.Resize(fyne.NewSize(1280, 720))
+	prefs := s.app.Preferences()
+	w.Resize(fyne.NewSize(
+		float32(prefs.FloatWithFallback(prefWindowWidth, 1280)),
+		float32(prefs.FloatWithFallback(prefWindowHeight, 720)),
+	))
+	w.SetCloseIntercept(func() {
+		s.saveSession()
+		w.Close()
+	})
 	w.CenterOnScreen()
 }
 
-func newTerminal(s *AppState) fyne.CanvasObject {
-	t := terminal.New()
+// saveSession keeps the window size and the form's values for the next launch.
+func (s *AppState) saveSession() {
+	prefs := s.app.Preferences()
+	size := s.mainWindow.Canvas().Size()
+	prefs.SetFloat(prefWindowWidth, float64(size.Width))
+	prefs.SetFloat(prefWindowHeight, float64(size.Height))
+	s.options.save()
+}
+
+// setSplitPanes switches between the single merged terminal and a stdout pane
+// stacked above a stderr pane, it must be called from the UI goroutine.
+func (s *session) setSplitPanes(split bool) {
+	if split {
+		s.termArea.Objects = []fyne.CanvasObject{container.NewVSplit(s.terminal, s.stderrTerminal)}
+	} else {
+		s.termArea.Objects = []fyne.CanvasObject{s.terminal}
+	}
+	// so that the new split gets the font size too
+	s.termFont.Refresh()
+}
+
+// showScrollback shows or hides the scrollback view beside the terminal, it
+// must be called from the UI goroutine.
+func (s *session) showScrollback(show bool) {
+	if show {
+		split := container.NewHSplit(s.termFont, s.scrollbackView.widget)
+		split.Offset = 0.6
+		s.center.Objects = []fyne.CanvasObject{split}
+	} else {
+		s.center.Objects = []fyne.CanvasObject{s.termFont}
+	}
+	s.center.Refresh()
+}
+
+// addActions binds the actions that have keyboard shortcuts, each acting on
+// the session in the selected tab.
+func (s *AppState) addActions() {
+	ctrlShift := fyne.KeyModifierControl | fyne.KeyModifierShift
+	s.keymap.add("run", "Run", keyBinding{fyne.KeyR, ctrlShift}, func() { s.current().run() })
+	s.keymap.add("run-again", "Run again", keyBinding{fyne.KeyE, ctrlShift}, func() { s.current().runAgain() })
+	s.keymap.add("new-session", "New session", keyBinding{fyne.KeyT, ctrlShift}, s.addSession)
+	// plain Ctrl+C has to reach the container as an interrupt
+	s.keymap.add("copy", "Copy", keyBinding{fyne.KeyC, ctrlShift}, func() { s.current().copySelection() })
+	s.keymap.add("paste", "Paste", keyBinding{fyne.KeyV, ctrlShift}, func() {
+		_, _ = s.current().terminal.Write([]byte(s.app.Clipboard().Content()))
+	})
+	// plain Ctrl+L has to reach the container, where most shells clear the
+	// screen on it themselves
+	s.keymap.add("clear", "Clear terminal", keyBinding{fyne.KeyL, ctrlShift}, func() { s.current().clearTerminal() })
+	s.keymap.add("reset", "Reset terminal", keyBinding{fyne.KeyK, ctrlShift}, func() {
+		// reset attributes, scroll region and cursor visibility, then clear
+		s.current().writeOutput("\033[0m\033[r\033[?25h\033[H\033[2J\033[3J")
+	})
+	ctrl := fyne.KeyModifierControl
+	s.keymap.add("font-bigger", "Bigger text", keyBinding{fyne.KeyEqual, ctrl}, s.growFont)
+	s.keymap.add("font-smaller", "Smaller text", keyBinding{fyne.KeyMinus, ctrl}, s.shrinkFont)
+	s.keymap.add("search", "Find in scrollback", keyBinding{fyne.KeyF, ctrlShift}, func() {
+		cur := s.current()
+		cur.scrollbackCheck.SetChecked(true)
+		cur.scrollbackView.showSearch()
+		s.mainWindow.Canvas().Focus(cur.scrollbackView.search)
+	})
+	s.keymap.add("prev-prompt", "Previous prompt", keyBinding{fyne.KeyUp, ctrlShift}, func() { s.current().jumpPrompt(-1) })
+	s.keymap.add("next-prompt", "Next prompt", keyBinding{fyne.KeyDown, ctrlShift}, func() { s.current().jumpPrompt(1) })
+}
+
+// copySelection puts the text selected in either terminal pane on the
+// clipboard, doing nothing if there isn't any.
+func (s *session) copySelection() {
+	text := s.terminal.SelectedText()
+	if text == "" {
+		text = s.stderrTerminal.SelectedText()
+	}
+	if text != "" {
+		s.app.Clipboard().SetContent(text)
+	}
+}
 
-	s.terminal = t
-	s.termSize = newTermSizeTracker(t)
-	return t
+// jumpPrompt moves the scrollback to the previous or next prompt, showing it
+// first if need be.
+func (s *session) jumpPrompt(dir int) {
+	s.scrollbackCheck.SetChecked(true)
+	s.scrollbackView.jumpPrompt(dir)
 }
 
+// setOutput records the writer that feeds the terminal for the current run.
+func (s *session) setOutput(w io.Writer) {
+	s.outputMu.Lock()
+	defer s.outputMu.Unlock()
+	s.output = w
+}
+
+// clearTerminal clears the screen, and the scrollback with it, during a run.
+// Between runs there is nothing connected to write to, so it does nothing.
+func (s *session) clearTerminal() {
+	s.writeOutput("\033[H\033[2J\033[3J")
+}
+
+// writeOutput sends text to the terminal as if it came from the container, it
+// does nothing if no run is active.
+func (s *session) writeOutput(text string) {
+	s.outputMu.Lock()
+	w := s.output
+	s.outputMu.Unlock()
+	if w == nil {
+		return
+	}
+	// the write blocks until the terminal has read it, keep that off the UI
+	// goroutine
+	go func() { _, _ = io.WriteString(w, text) }()
+}
+
+// termSizeTracker follows the size of the terminal widget, which is what the
+// container's TTY should match. There's no SIGWINCH when a GUI window resizes,
+// so this is what drives resizing the container. A size of zero, as the
+// widget has while its window is minimized, is no size to give the TTY, so the
+// last one it had before is kept.
 type termSizeTracker struct {
 	ch         chan terminal.Config
+	changed    chan struct{}
+	known      chan struct{}
+	knownOnce  sync.Once
 	mu         sync.Mutex
 	rows, cols uint
 }
 
+const (
+	// termSizeWait is how long a run waits for the widget to first report its
+	// size, before going with fallbackRows by fallbackCols.
+	termSizeWait = 500 * time.Millisecond
+	fallbackRows = 25
+	fallbackCols = 80
+)
+
 func newTermSizeTracker(t *terminal.Terminal) *termSizeTracker {
 	tracker := &termSizeTracker{
-		ch: make(chan terminal.Config, 1),
+		ch:      make(chan terminal.Config, 1),
+		changed: make(chan struct{}, 1),
+		known:   make(chan struct{}),
 	}
 	go func() {
 		for cfg := range tracker.ch {
+			if cfg.Rows == 0 || cfg.Columns == 0 {
+				continue
+			}
 			tracker.mu.Lock()
+			resized := cfg.Rows != tracker.rows || cfg.Columns != tracker.cols
 			tracker.rows, tracker.cols = cfg.Rows, cfg.Columns
 			tracker.mu.Unlock()
+			tracker.knownOnce.Do(func() { close(tracker.known) })
+			if resized {
+				// only the latest size matters, a pending notice covers it
+				select {
+				case tracker.changed <- struct{}{}:
+				default:
+				}
+			}
 		}
 	}()
 	t.AddListener(tracker.ch)
@@ -109,42 +388,348 @@ func (t *termSizeTracker) LastSize() (rows uint, cols uint) {
 	return t.rows, t.cols
 }
 
-func (s *AppState) run() {
-	go s.reallyRun()
+// WaitSize is LastSize, waiting up to timeout for the widget to report a
+// size if it hasn't yet. ok is false if it didn't.
+func (t *termSizeTracker) WaitSize(timeout time.Duration) (rows, cols uint, ok bool) {
+	select {
+	case <-t.known:
+	case <-time.After(timeout):
+	}
+	rows, cols = t.LastSize()
+	return rows, cols, rows != 0 && cols != 0
+}
+
+// Changed receives whenever the size has changed since it last did.
+func (t *termSizeTracker) Changed() <-chan struct{} {
+	return t.changed
+}
+
+func (s *session) run() {
+	opts, err := s.options.options()
+	if err != nil {
+		dialog.NewError(err, s.mainWindow).Show()
+		s.abandon(err)
+		return
+	}
+	s.start(opts)
+}
+
+// runAgain repeats the last run as it was, without reading the form again.
+func (s *session) runAgain() {
+	if s.lastRun == nil {
+		return
+	}
+	s.start(*s.lastRun)
+}
+
+// start begins a run with opts, unless one is already in progress. It must be
+// called from the UI goroutine.
+func (s *session) start(opts runOptions) {
+	if s.termBroken.Load() || !s.running.CompareAndSwap(false, true) {
+		// e.g. a double click, or the shortcut while a run is in progress
+		return
+	}
+	if s.daemonErr != nil && opts.Replay == "" {
+		// the Run button is disabled while it is, but not the shortcuts
+		s.running.Store(false)
+		err := fmt.Errorf("the Docker daemon can't be reached: %w", s.daemonErr)
+		dialog.NewError(err, s.mainWindow).Show()
+		s.abandon(err)
+		return
+	}
+	if err := opts.validate(); err != nil {
+		s.running.Store(false)
+		dialog.NewError(err, s.mainWindow).Show()
+		s.abandon(err)
+		return
+	}
+	if opts.Privileged && opts.Attach == "" {
+		// asked every time, as it is easily left on from an earlier run
+		s.confirmPrivileged(opts, func(ok bool) {
+			if !ok {
+				s.running.Store(false)
+				s.abandon(errPrivilegedDeclined)
+				return
+			}
+			s.launch(opts)
+		})
+		return
+	}
+	s.launch(opts)
+}
+
+// errPrivilegedDeclined is why a run didn't start when the user said no to it
+// running privileged.
+var errPrivilegedDeclined = errors.New("privileged run cancelled")
+
+// abandon tells exitWhenDone, if set, that the run it is waiting for won't
+// happen, as it failed before it could start. Otherwise the app would wait
+// for it forever.
+func (s *session) abandon(err error) {
+	if s.exitWhenDone != nil {
+		s.exitWhenDone(-1, err)
+	}
+}
+
+// launch is start once opts are known to be fine to run.
+func (s *session) launch(opts runOptions) {
+	if opts.Attach == "" && opts.Image != "" && opts.BuildContext == "" {
+		s.options.rememberImage(opts.Image)
+	}
+	// in case the app doesn't get to close its window
+	s.options.save()
+	s.lastRun = &opts
+	s.runButton.Disable()
+	s.runAgainButton.Disable()
+	go func() {
+		// deferred so it happens however the run ends
+		defer func() {
+			s.running.Store(false)
+			fyne.Do(func() {
+				if !s.termBroken.Load() {
+					if s.daemonErr == nil {
+						s.runButton.Enable()
+					}
+					s.runAgainButton.Enable()
+				}
+			})
+		}()
+		s.reallyRun(opts)
+	}()
 }
 
-func (s *AppState) reallyRun() {
+func (s *session) reallyRun(opts runOptions) {
+	fyne.Do(s.spinner.Start)
+	defer fyne.Do(s.spinner.Stop)
+	s.setStatus("Starting", false)
+	// the last run's container, kept, is no longer the one of interest
+	fyne.Do(func() { s.setContainerID("") })
+	s.waiting.show()
+	defer s.waiting.hide()
+
 	getTermSize := func() (uint, uint, error) {
-		r, c := s.termSize.LastSize()
-		if r == 0 || c == 0 {
-			return 25, 80, errors.New("terminal size unknown")
+		if r, c, ok := s.termSize.WaitSize(termSizeWait); ok {
+			return r, c, nil
 		}
-		return r, c, nil
+		// a guess, the real size replaces it as the widget reports it
+		return fallbackRows, fallbackCols, nil
 	}
 
 	// two pipes, one for reading from the terminal, one for writing to it
 	stdinR, stdinW := io.Pipe()
 	stdoutR, stdoutW := io.Pipe()
+	termDone := make(chan struct{})
 	go func() {
-		must(s.terminal.RunWithConnection(stdinW, stdoutR))
+		defer close(termDone)
+		s.runTerminal(s.terminal, stdinW, stdoutR)
 	}()
 
-	defer stdinR.Close()
+	// Tear down in a fixed order, once everything writing to the terminal has
+	// finished (the deferred calls below run first): closing stdoutW gives the
+	// terminal EOF, which makes it close stdinW in turn so any reader of stdinR
+	// sees EOF too. Closing stdinR after that catches any write the terminal
+	// was still blocked in.
+	defer func() {
+		_ = stdoutW.Close()
+		<-termDone
+		_ = stdinR.Close()
+	}()
 
-	_, _ = fmt.Fprint(stdoutW, "\033[H\033[2J\033[3J") // clear the screen
-	_, _ = fmt.Fprint(stdoutW, "Asked to do the thing\r\n")
+	// everything shown in the terminal is also kept, in full, in the scrollback
+	// and the transcript
+	maxLen := maxLineLength(s.app.Preferences())
+	batch := outputBatch(s.app.Preferences())
+	backlogMode, backlogLines := backlogSettings(s.app.Preferences())
+	drop := dropOffscreen(s.app.Preferences())
+	var truncators []*lineTruncator
+	var paused []*pausableWriter
+	var batches []*batchWriter
+	var droppers []*offscreenDropper
+	var backlogs []*backlogWriter
+	// rows is the terminal's height, for leaving out what would scroll
+	// straight off it, nil to pass everything on
+	toTerminal := func(w io.Writer, rows func() uint) io.Writer {
+		if rows != nil && drop {
+			d := newOffscreenDropper(w, rows)
+			droppers = append(droppers, d)
+			w = d
+		}
+		if backlogMode != backlogWait {
+			l := newBacklogWriter(w, backlogLines, backlogMode == backlogSummarize)
+			backlogs = append(backlogs, l)
+			w = l
+		}
+		b := newBatchWriter(w, batch)
+		batches = append(batches, b)
+		p := s.background.wrap(b)
+		paused = append(paused, p)
+		if maxLen <= 0 {
+			return p
+		}
+		t := newLineTruncator(p, maxLen)
+		truncators = append(truncators, t)
+		return t
+	}
+	if err := s.transcript.reset(); err != nil {
+		fyne.LogError("unable to record output, it won't be possible to save it", err)
+	}
+	if err := s.recording.reset(); err != nil {
+		fyne.LogError("unable to record output, it won't be possible to replay it", err)
+	}
+	meter := &outputMeter{}
+	tee := func(w io.Writer, rows func() uint) io.Writer {
+		bell := &bellWatcher{ring: s.bell.ring}
+		if s.viewers != nil {
+			return io.MultiWriter(toTerminal(w, rows), s.scrollback, &s.transcript, &s.recording, bell, meter, s.viewers)
+		}
+		return io.MultiWriter(toTerminal(w, rows), s.scrollback, &s.transcript, &s.recording, bell, meter)
+	}
+	stdout := tee(stdoutW, func() uint {
+		rows, _ := s.termSize.LastSize()
+		return rows
+	})
 
-	ctx, cancel := context.WithCancel(s.ctx)
-	defer cancel()
-	dc, err := newRawDockerClient()
+	fyne.Do(func() { s.setSplitPanes(opts.SplitStreams) })
+	var stderr io.Writer
+	if opts.SplitStreams {
+		// typing in the stderr pane still goes to the container's stdin
+		stderrR, stderrW := io.Pipe()
+		stderrDone := make(chan struct{})
+		go func() {
+			defer close(stderrDone)
+			s.runTerminal(s.stderrTerminal, nopWriteCloser{stdinW}, stderrR)
+		}()
+		defer func() {
+			_ = stderrW.Close()
+			<-stderrDone
+		}()
+		_, _ = fmt.Fprint(stderrW, "\033[H\033[2J\033[3J") // clear the screen
+		// its size isn't followed, so it is given everything
+		stderr = tee(stderrW, nil)
+	}
+	// before the pipes are closed
+	defer func() {
+		for _, t := range truncators {
+			_ = t.Flush()
+		}
+		s.background.release(paused)
+		for _, b := range batches {
+			_ = b.Flush()
+		}
+		for _, l := range backlogs {
+			_ = l.Close()
+		}
+		for _, d := range droppers {
+			_ = d.Flush()
+		}
+	}()
+
+	_, _ = fmt.Fprint(stdout, "\033[H\033[2J\033[3J") // clear the screen
+	_, _ = fmt.Fprint(stdout, "Asked to do the thing\r\n")
+	if opts.Replay == "" {
+		s.recordRun(opts, stdout)
+	}
+	s.scrollback.setPromptPattern(promptPattern(s.app.Preferences()))
+	s.scrollback.setMaxLines(scrollbackLines(s.app.Preferences()))
+	s.setOutput(paused[0])
+	defer s.setOutput(nil)
+
+	ctx, cancel := context.WithCancelCause(s.ctx)
+	defer cancel(nil)
+	s.setStop(cancel)
+	defer s.setStop(nil)
+	go s.watchRate(ctx, meter)
+	if opts.Replay != "" {
+		s.replayRun(ctx, opts, stdout)
+		return
+	}
+	dc, err := newDockerClient(opts.daemon())
+	// the container's, or -1 until it has exited
+	var exitCode atomic.Int64
+	exitCode.Store(-1)
+	if s.exitWhenDone != nil {
+		defer func() { s.exitWhenDone(int(exitCode.Load()), err) }()
+	}
 	if err != nil {
-		panic(err)
+		lifecycleLog.Error("docker client failed", "host", opts.DockerHost, "err", err)
+		s.setStatus("Error: unable to connect to docker", true)
+		fyne.Do(func() {
+			dialog.NewError(fmt.Errorf("unable to connect to docker: %w", err), s.mainWindow).Show()
+		})
+		return
 	}
 
+	lifecycleLog.Info("docker client created", "host", dc.DaemonHost())
 	defer dc.Close()
+	defer s.setActive(nil)
+	var finished atomic.Bool
+	hooks := runHooks{
+		created: func(id, image string) {
+			s.setActive(&activeContainer{dc, id})
+			title := fmt.Sprintf("%s (%s)", image, shortID(id))
+			fyne.Do(func() {
+				s.setRunTitle(title)
+				s.setContainerID(id)
+			})
+		},
+		status: s.setStatus,
+		output: s.waiting.hide,
+		finished: func(text string, failed bool) {
+			finished.Store(true)
+			s.setStatus(text, failed)
+		},
+		exited:    func(code int) { exitCode.Store(int64(code)) },
+		nameInUse: s.askNameConflict,
+	}
+	defer fyne.Do(func() {
+		s.setRunTitle("")
+		// one still there is worth being able to find, e.g. to see its logs
+		if !opts.KeepContainer && opts.Attach == "" {
+			s.setContainerID("")
+		}
+	})
 
-	err = dockerRun(ctx, dc, getTermSize, stdinR, stdoutW)
+	var stdin io.Reader = stdinR
+	if opts.IdleTimeout > 0 {
+		input := newInputTracker(stdinR)
+		stdin = input
+		go s.watchIdle(ctx, input, opts.IdleTimeout, func() {
+			_, _ = fmt.Fprintf(stdout, "\r\nNo input for %v, stopping the container\r\n", opts.IdleTimeout)
+			cancel(fmt.Errorf("%w after %v without input", errStopped, opts.IdleTimeout))
+		})
+	}
+
+	// the docker CLI's way of leaving the container running, the daemon is
+	// told the same keys so that it doesn't watch for its own
+	var keys []byte
+	opts.DetachKeys, keys = detachKeys(s.app.Preferences())
+	stdin = newDetachingReader(stdin, keys)
+
+	if opts.Attach == "" {
+		err = ensureVolumes(ctx, dc, opts.Volumes, func(name string) bool {
+			return s.confirm("Create Volume", fmt.Sprintf("Volume %q does not exist, create it?", name))
+		})
+	}
+	if err == nil {
+		err = dockerRun(ctx, dc, opts, getTermSize, s.termSize.Changed(), hooks, stdin, stdout, stderr)
+	}
+	// The copy of the input to the container can't be interrupted, so it is
+	// still waiting for the next keystroke, which would be sent nowhere. Ending
+	// the input here stops it, and the terminal's writes fail instead of
+	// blocking. The teardown above closing it again is harmless.
+	_ = stdinR.CloseWithError(errRunEnded)
+	if err != nil && ctx.Err() != nil && errors.Is(err, context.Canceled) {
+		// stopped on purpose before the container was running, e.g. during a
+		// slow pull, not a failure
+		_, _ = fmt.Fprintf(stdout, "\r\nRun %v before the container started\r\n", stopReason(ctx))
+		s.setStatus("Stopped", false)
+		return
+	}
 	if err != nil {
+		if !finished.Load() {
+			// it went wrong before the container was there to exit
+			s.setStatus("Error: "+err.Error(), true)
+		}
 		fyne.Do(func() {
 			dialog.NewError(fmt.Errorf("run failed: %w", err), s.mainWindow).Show()
 		})
@@ -152,23 +737,195 @@ func (s *AppState) reallyRun() {
 	}
 }
 
-func newRawDockerClient() (*client.Client, error) {
-	return client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+// runExitStatus is what the app exits with for -exit-with-run: the container's
+// exit code, or when the run failed without one 125, as with docker run.
+func runExitStatus(exitCode int, err error) int {
+	switch {
+	case exitCode >= 0:
+		return exitCode
+	case err != nil:
+		return 125
+	default:
+		return 0
+	}
+}
+
+// setStop records how to cancel the current run, or nil once it is over, and
+// enables the Stop button to match.
+func (s *session) setStop(cancel context.CancelCauseFunc) {
+	s.stopMu.Lock()
+	s.stopRun = cancel
+	s.stopMu.Unlock()
+	fyne.Do(func() {
+		if cancel != nil {
+			s.stopButton.Enable()
+		} else {
+			s.stopButton.Disable()
+		}
+	})
+}
+
+// stop cancels the current run, which stops and deletes its container.
+func (s *session) stop() {
+	s.stopMu.Lock()
+	cancel := s.stopRun
+	s.stopMu.Unlock()
+	if cancel != nil {
+		cancel(errStoppedByUser)
+	}
+}
+
+// runTerminal shows out in t, and sends what is typed into it to in, for the
+// length of a run. Should the terminal fail, even by panicking on something
+// the container wrote, running again is disabled rather than the app taken
+// down.
+func (s *session) runTerminal(t *terminal.Terminal, in io.WriteCloser, out io.Reader) {
+	defer func() {
+		// if it stopped reading early, don't leave the run blocked writing to it
+		_, _ = io.Copy(io.Discard, out)
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			fyne.LogError("terminal panicked:\n"+string(debug.Stack()), nil)
+			s.terminalFailed(fmt.Errorf("panic: %v", r))
+		}
+	}()
+	if err := t.RunWithConnection(in, out); err != nil {
+		s.terminalFailed(err)
+	}
+}
+
+// terminalFailed reports that a terminal's connection broke. Running again in
+// the session is refused from then on, as the terminal can't be trusted to show
+// it.
+func (s *session) terminalFailed(err error) {
+	fyne.LogError("terminal connection failed", err)
+	s.termBroken.Store(true)
+	fyne.Do(func() {
+		s.runButton.Disable()
+		s.runAgainButton.Disable()
+		dialog.NewError(fmt.Errorf("the terminal failed, open a new session to run again: %w", err), s.mainWindow).Show()
+	})
+}
+
+// confirm asks the user a yes or no question from a background goroutine,
+// blocking until they answer it.
+func (s *AppState) confirm(title, message string) bool {
+	answer := make(chan bool, 1)
+	fyne.Do(func() {
+		dialog.ShowConfirm(title, message, func(ok bool) { answer <- ok }, s.mainWindow)
+	})
+	return <-answer
+}
+
+// nopWriteCloser lets several terminals share one input pipe without any of
+// them closing it.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+const (
+	appTitle = "Slow Terminal Demo"
+
+	// prefWindowWidth and prefWindowHeight are the main window's size when it
+	// was last closed.
+	prefWindowWidth  = "window.width"
+	prefWindowHeight = "window.height"
+
+	// maxWaitRetries is how many times a broken ContainerWait is re-issued
+	// before the run is failed.
+	maxWaitRetries = 3
+	waitRetryDelay = 500 * time.Millisecond
+
+	// defaultImage runs the demo workload, unless another image is given or
+	// one is built.
+	defaultImage = "debian:stable-slim"
+	// containerTerm is what the container is told the terminal supports.
+	containerTerm = "xterm-256color"
+)
+
+// newDockerClient is what a run connects to the daemon with, which tests
+// replace with a fake.
+var newDockerClient = func(d dockerDaemon) (dockerClient, error) {
+	dc, err := newRawDockerClient(d)
+	if err != nil {
+		return nil, err
+	}
+	return dc, nil
+}
+
+// newRawDockerClient connects to d, or to the daemon the environment
+// (DOCKER_HOST etc) says to for what it leaves empty.
+func newRawDockerClient(d dockerDaemon) (*client.Client, error) {
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if d.tls.enabled() {
+		opt, err := d.tls.clientOpt()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opt)
+	}
+	if d.host != "" {
+		// after FromEnv, so that DOCKER_TLS_VERIFY etc still apply, and after
+		// any client of our own, which it sets the transport of
+		opts = append(opts, client.WithHost(d.host))
+	}
+	return client.NewClientWithOpts(opts...)
+}
+
+// validateDockerHost checks host is a daemon address the client can use.
+func validateDockerHost(host string) error {
+	u, err := client.ParseHostURL(host)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "unix", "tcp", "http", "https", "npipe":
+		return nil
+	case "ssh":
+		return errors.New("ssh hosts need the docker CLI, use a tcp:// or unix:// address")
+	default:
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
 }
 
 func dockerRun(
 	ctx context.Context,
-	dc *client.Client,
+	dc dockerClient,
+	opts runOptions,
 	getTermSize func() (rows, cols uint, err error),
+	resized <-chan struct{},
+	hooks runHooks,
 	stdin io.Reader,
-	stdout io.Writer,
+	stdout, stderr io.Writer,
 ) (finalErr error) {
+	if opts.Attach != "" && opts.Exec {
+		return execExisting(ctx, dc, opts, getTermSize, resized, hooks, stdin, stdout, stderr)
+	}
+	if opts.Attach != "" {
+		return attachExisting(ctx, dc, opts, getTermSize, resized, hooks, stdin, stdout, stderr)
+	}
+	// as the daemon wants it, e.g. "SIGTERM" for "term" or "15"
+	stopSignal := opts.StopSignal
+	if stopSignal != "" {
+		var err error
+		if stopSignal, err = parseSignal(stopSignal); err != nil {
+			return fmt.Errorf("invalid stop signal: %w", err)
+		}
+	}
 	config := &dockerContainer.Config{
-		StdinOnce:    true,
+		Hostname:     opts.Hostname,
+		Domainname:   opts.Domainname,
+		Labels:       withAppLabel(opts.Labels),
+		StopSignal:   stopSignal,
+		StopTimeout:  opts.StopTimeout,
+		StdinOnce:    stderr == nil, // or, without a TTY, detaching would close its input
 		OpenStdin:    true,
 		AttachStdout: true,
 		AttachStderr: true,
-		Tty:          true,
+		Tty:          stderr == nil,
 		Cmd: []string{
 			// The real app runs a container that does a bunch of stuff and emits a
 			// lot of output. Here we just spew out some convenient text to replicate the scale
@@ -176,56 +933,183 @@ func dockerRun(
 			"/bin/sh", "-c",
 			"apt-get update ; apt-get -y install lz4 ; lz4cat /var/lib/apt/lists/*_Packages.lz4",
 		},
-		Image: "debian:stable-slim",
+		Image: defaultImage,
+	}
+	if opts.Image != "" && opts.Image != defaultImage {
+		config.Image, config.Cmd = opts.Image, nil
+	}
+	if opts.BuildContext != "" {
+		hooks.status("Building image", false)
+		image, err := buildImage(ctx, dc, opts.BuildContext, opts.Dockerfile, stdout)
+		if err != nil {
+			return err
+		}
+		// run what the Dockerfile says to, unless told otherwise
+		config.Image, config.Cmd = image, nil
+	}
+	if opts.Cmd != nil {
+		config.Cmd = opts.Cmd
+	}
+	config.Entrypoint = opts.Entrypoint
+	config.Env = opts.Env
+	config.WorkingDir = opts.WorkingDir
+	config.User = opts.User
+	config.ExposedPorts = opts.ExposedPorts
+	if len(opts.Commands) > 0 {
+		config.Cmd = []string{"/bin/sh", "-c", sequenceScript(opts.Commands, opts.StopOnFailure)}
 	}
 	mounts := []mount.Mount{
 		// real app does some stuff here
 	}
+	mounts = append(mounts, opts.Volumes...)
+	if opts.ForwardLocale {
+		config.Env = mergeEnv(hostLocaleEnv(), config.Env)
+		if m, ok := localtimeMount(); ok {
+			mounts = append(mounts, m)
+		}
+	}
 	hostConfig := &dockerContainer.HostConfig{
-		Mounts:     mounts,
-		Privileged: true,
-		AutoRemove: true,
+		Mounts:         mounts,
+		PortBindings:   opts.PortBindings,
+		Privileged:     opts.Privileged,
+		ReadonlyRootfs: opts.ReadonlyRootfs,
+		Tmpfs:          opts.Tmpfs,
+		AutoRemove:     !opts.KeepContainer,
+		NetworkMode:    dockerContainer.NetworkMode(opts.Network),
+		ExtraHosts:     opts.ExtraHosts,
+		Resources: dockerContainer.Resources{
+			Memory:              opts.Memory,
+			NanoCPUs:            opts.NanoCPUs,
+			BlkioWeight:         opts.BlkioWeight,
+			BlkioWeightDevice:   opts.BlkioWeightDevice,
+			BlkioDeviceReadBps:  opts.BlkioDeviceReadBps,
+			BlkioDeviceWriteBps: opts.BlkioDeviceWriteBps,
+		},
+	}
+	if opts.OomScoreAdj != nil {
+		hostConfig.OomScoreAdj = *opts.OomScoreAdj
+	}
+	if opts.OomKillDisable {
+		hostConfig.OomKillDisable = &opts.OomKillDisable
 	}
 
-	return runContainer(ctx, dc, config, hostConfig, getTermSize, stdin, stdout)
+	netConfig, err := networkingConfig(ctx, dc, opts.Network, stdout)
+	if err != nil {
+		return err
+	}
+
+	return runContainer(ctx, dc, config, hostConfig, netConfig, opts, getTermSize, resized, hooks, stdin, stdout, stderr)
 }
 
 func runContainer(
 	ctx context.Context,
-	dc *client.Client,
+	dc dockerClient,
 	cfg *dockerContainer.Config,
 	hostCfg *dockerContainer.HostConfig,
+	netCfg *network.NetworkingConfig,
+	opts runOptions,
 	getTermSize func() (rows, cols uint, err error),
+	resized <-chan struct{},
+	hooks runHooks,
 	stdin io.Reader,
-	stdout io.Writer,
+	stdout, stderr io.Writer,
 ) (finalErr error) {
 	cfg.AttachStdout = true
 	cfg.AttachStderr = true
-	cfg.Tty = true
-	cfg.Env = append(cfg.Env, "TERM=xterm-256color")
-
-	created, err := dc.ContainerCreate(
-		ctx,
-		cfg,
-		hostCfg,
-		nil,
-		nil,
-		"",
-	)
+	// without a TTY docker multiplexes the two streams, which we split apart for
+	// the caller's stderr
+	cfg.Tty = stderr == nil
+	// the user can still say otherwise, e.g. for a program that doesn't know
+	// about 256 colours
+	cfg.Env = mergeEnv([]string{"TERM=" + containerTerm}, cfg.Env)
+
+	create := func(name string) (dockerContainer.CreateResponse, error) {
+		return dc.ContainerCreate(ctx, cfg, hostCfg, netCfg, nil, name)
+	}
+	created, err := createNamed(ctx, dc, opts.Name, create, hooks.nameInUse)
+	if cerrdefs.IsNotFound(err) {
+		// the image isn't there, as the daemon doesn't pull it itself
+		hooks.status("Pulling "+cfg.Image, false)
+		if err := pullImage(ctx, dc, cfg.Image, stdout); err != nil {
+			return err
+		}
+		created, err = createNamed(ctx, dc, opts.Name, create, hooks.nameInUse)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create %s container: %w", cfg.Image, err)
 	}
+	log := lifecycleLog.With("container", shortID(created.ID))
+	log.Info("container created", "image", cfg.Image)
+	hooks.created(created.ID, cfg.Image)
+	// the daemon drops settings the kernel doesn't support (e.g. block IO
+	// limits) with a warning rather than failing
+	for _, w := range created.Warnings {
+		_, _ = fmt.Fprintf(stdout, "Warning: %s\r\n", w)
+	}
+	if info, err := dc.ContainerInspect(ctx, created.ID); err == nil && info.Config != nil {
+		_, _ = fmt.Fprintf(stdout, "Container hostname: %s\r\n", effectiveHostname(info.Config))
+		printVolumeMounts(stdout, info.Mounts)
+	}
 
+	if opts.KeepContainer {
+		defer func() {
+			_, _ = fmt.Fprintf(stdout, "Container kept as %s\r\n", created.ID)
+		}()
+	}
 	deleted := false
 	deleteContainer := func() error {
 		deleted = true
+		if opts.KeepContainer {
+			// left for the user to inspect, and remove, themselves
+			return nil
+		}
 		// don't let context cancellation prevent us from deleting the container
 		err := dc.ContainerRemove(context.Background(), created.ID, dockerContainer.RemoveOptions{Force: true})
-		if err != nil {
+		// after a graceful stop auto-remove may have beaten us to it
+		if err != nil && !cerrdefs.IsNotFound(err) && !cerrdefs.IsConflict(err) {
+			log.Error("container remove failed", "err", err)
 			return fmt.Errorf("failed to remove %s container: %w", cfg.Image, err)
 		}
+		log.Info("container removed")
 		return nil
 	}
+	// stoppedExit is the exit code of a container stopped on purpose, which
+	// the waiter has given up on by then, or -1 if it isn't known
+	stoppedExit := -1
+	stopContainer := func() error {
+		// let the container exit cleanly, using its configured stop signal and
+		// timeout, before we force remove it (if it isn't being kept)
+		grace := "up to its stop timeout"
+		switch {
+		case cfg.StopTimeout == nil:
+		case *cfg.StopTimeout < 0:
+			grace = "as long as it takes"
+		default:
+			grace = fmt.Sprintf("up to %ds", *cfg.StopTimeout)
+		}
+		_, _ = fmt.Fprintf(stdout, "\r\nStopping container, giving it %s to exit\r\n", grace)
+		hooks.status("Stopping", false)
+		log.Info("container stopping", "reason", context.Cause(ctx))
+		// waited for from before the stop, as the waiter is from before the
+		// start, so that auto-remove can't get in first
+		waitCtx, cancelWait := context.WithCancel(context.Background())
+		defer cancelWait()
+		onStopped, onErr := dc.ContainerWait(waitCtx, created.ID, dockerContainer.WaitConditionNotRunning)
+		err := dc.ContainerStop(context.Background(), created.ID, dockerContainer.StopOptions{})
+		if err != nil && !cerrdefs.IsNotFound(err) {
+			_, _ = fmt.Fprintf(stdout, "\r\nFailed to stop container gracefully: %v\r\n", err)
+		}
+		select {
+		case stopped := <-onStopped:
+			stoppedExit = int(stopped.StatusCode)
+			log.Info("container stopped", "exitCode", stoppedExit)
+		case err := <-onErr:
+			log.Warn("stopped container's exit code unknown", "err", err)
+		case <-time.After(stopWaitTimeout):
+			log.Warn("stopped container's exit code unknown", "err", "timed out")
+		}
+		return deleteContainer()
+	}
 	defer func() {
 		if !deleted {
 			err := deleteContainer()
@@ -235,34 +1119,43 @@ func runContainer(
 		}
 	}()
 
-	// attach before starting so we get all the info
-	attachOpts := dockerContainer.AttachOptions{
-		Stream: true,
-		Stdout: true,
-		Stderr: true,
+	// count what the container itself writes, not our own messages around it
+	var written atomic.Int64
+	var output, errOutput io.Writer = byteCounter{stdout, &written}, nil
+	if stderr != nil {
+		errOutput = byteCounter{stderr, &written}
+	}
+
+	if opts.MaxRuntime > 0 {
+		// from here, so that a slow pull or build doesn't count towards it
+		var cancel context.CancelCauseFunc
+		ctx, cancel = context.WithCancelCause(ctx)
+		defer cancel(nil)
+		limit := time.AfterFunc(opts.MaxRuntime, func() {
+			_, _ = fmt.Fprintf(stdout, "\r\nContainer exceeded its time limit of %v, stopping\r\n", opts.MaxRuntime)
+			cancel(fmt.Errorf("%w after exceeding its time limit of %v", errStopped, opts.MaxRuntime))
+		})
+		defer limit.Stop()
 	}
-	attached, err := dc.ContainerAttach(ctx, created.ID, attachOpts)
+
+	// attach before starting so we get all the info
+	doIO, _, err := attachContainer(ctx, dc, created.ID, cfg.Image, opts.DetachKeys, getTermSize, resized, hooks,
+		stdin, output, errOutput)
 	if err != nil {
-		// TODO: delete the container
-		return fmt.Errorf("unable to attach to %s container: %w", cfg.Image, err)
+		// the deferred delete removes the container, it was set up first for
+		// this
+		return err
 	}
 
 	eg, egCtx := errgroup.WithContext(ctx)
 	// run IO concurrent with waiter
+	var detached atomic.Bool
 	eg.Go(func() error {
-		defer attached.Close()
-		if err := interactiveTTY(egCtx, attached, getTermSize,
-			func(ctx context.Context, r dockerContainer.ResizeOptions) error {
-				return dc.ContainerResize(ctx, created.ID, r)
-			},
-			func(ctx context.Context, s os.Signal) error {
-				return dc.ContainerKill(ctx, created.ID, unix.SignalName(s.(unix.Signal)))
-			},
-			stdin, stdout,
-		); err != nil {
-			return fmt.Errorf("failed doing io to %s container: %w", cfg.Image, err)
+		err := doIO(egCtx)
+		if errors.Is(err, errDetached) {
+			detached.Store(true)
 		}
-		return nil
+		return err
 	})
 	// waiter needs to start before we start the container
 	waiting := make(chan struct{})
@@ -272,30 +1165,68 @@ func runContainer(
 	exitCode := -1
 	eg.Go(func() error {
 		defer close(ended)
-		// container should stop on its own, wait for it and then remove it
-		onStopped, onErr := dc.ContainerWait(ctx, created.ID, dockerContainer.WaitConditionRemoved)
+		// container should stop on its own, wait for it and then remove it, or
+		// just for it to stop if it is being kept as it won't be removed
+		condition := dockerContainer.WaitConditionRemoved
+		if opts.KeepContainer {
+			condition = dockerContainer.WaitConditionNotRunning
+		}
+		onStopped, onErr := dc.ContainerWait(ctx, created.ID, condition)
 		close(waiting)
 		<-started
-		select {
-		case <-egCtx.Done():
-			return egCtx.Err()
-		case stopped := <-onStopped:
-			// we used autoremove so the container is gone now
-			deleted = true
-			exitCode = int(stopped.StatusCode)
-			if stopped.Error != nil {
-				return fmt.Errorf(
-					"failed waiting for %s container to stop: %s (%d)",
-					cfg.Image,
-					stopped.Error.Message,
-					stopped.StatusCode,
-				)
-			} else {
-				// stopped gracefully (though maybe with a non-zero exit code)
-				return nil
+		// the exit code as last inspected, in case the container is removed while
+		// the wait is being re-issued
+		inspectedExit := -1
+		for retries := 0; ; retries++ {
+			select {
+			case <-egCtx.Done():
+				return egCtx.Err()
+			case stopped := <-onStopped:
+				// we used autoremove so the container is gone now, unless it is
+				// being kept
+				deleted = true
+				exitCode = int(stopped.StatusCode)
+				log.Info("container waited", "exitCode", exitCode)
+				if stopped.Error != nil {
+					return fmt.Errorf(
+						"failed waiting for %s container to stop: %s (%d)",
+						cfg.Image,
+						stopped.Error.Message,
+						stopped.StatusCode,
+					)
+				} else {
+					// stopped gracefully (though maybe with a non-zero exit code)
+					return nil
+				}
+			case err := <-onErr:
+				if egCtx.Err() != nil {
+					return egCtx.Err()
+				}
+				// the wait stream can break without anything having happened to the
+				// container, check what state it is really in before giving up
+				info, iErr := dc.ContainerInspect(egCtx, created.ID)
+				if cerrdefs.IsNotFound(iErr) {
+					deleted = true
+					if inspectedExit >= 0 {
+						exitCode = inspectedExit
+						return nil
+					}
+					return fmt.Errorf("%s container was removed while waiting for it, exit code unknown: %w", cfg.Image, err)
+				}
+				if retries >= maxWaitRetries || iErr != nil {
+					return fmt.Errorf("failed waiting for %s container to stop/delete: %w", cfg.Image, errors.Join(err, iErr))
+				}
+				if info.State != nil && !info.State.Running {
+					inspectedExit = info.State.ExitCode
+				}
+				_, _ = fmt.Fprintf(stdout, "\r\nWaiting for container failed (%v), retrying\r\n", err)
+				select {
+				case <-egCtx.Done():
+					return egCtx.Err()
+				case <-time.After(waitRetryDelay):
+				}
+				onStopped, onErr = dc.ContainerWait(ctx, created.ID, condition)
 			}
-		case err := <-onErr:
-			return fmt.Errorf("failed waiting for %s container to stop/delete: %w", cfg.Image, err)
 		}
 	})
 	eg.Go(func() error {
@@ -312,6 +1243,22 @@ func runContainer(
 		if err != nil {
 			return fmt.Errorf("unable to start %s container: %w", cfg.Image, err)
 		}
+		log.Info("container started")
+		info, err := dc.ContainerInspect(ctx, created.ID)
+		if err != nil {
+			hooks.status("Running", false)
+			return nil
+		}
+		// host ports left to the daemon are only known once it has started
+		if len(hostCfg.PortBindings) > 0 && info.NetworkSettings != nil {
+			printPorts(stdout, info.NetworkSettings.Ports)
+		}
+		// started isn't ready for a service with a HEALTHCHECK
+		if info.State != nil && info.State.Health != nil {
+			eg.Go(func() error { return waitHealthy(egCtx, dc, created.ID, ended, hooks) })
+		} else {
+			hooks.status("Running", false)
+		}
 		return nil
 	})
 	eg.Go(func() error {
@@ -321,30 +1268,337 @@ func runContainer(
 			// don't kill it if it ends on its own
 			return nil
 		case <-egCtx.Done():
-			return deleteContainer()
+			if detached.Load() {
+				return nil
+			}
+			return stopContainer()
 		}
 	})
 
 	err = eg.Wait()
+	if exitCode < 0 {
+		exitCode = stoppedExit
+	}
+
+	if detached.Load() {
+		// left running, so not for the deferred delete to remove
+		deleted = true
+		_, _ = fmt.Fprintf(stdout, "\r\n\r\nDetached from container %s, which is still running\r\n", created.ID)
+		hooks.finished("Detached, the container is still running", false)
+		return nil
+	}
 
+	if ctx.Err() != nil && !errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+		// Stopped on purpose: the exit code is just what killing it gave, and
+		// the rest of the group only failed as it was cancelled. Failing to
+		// remove the container is still reported, by the deferred delete.
+		_, _ = fmt.Fprintf(stdout, "\r\n\r\nContainer %v (exit code %d)\r\n", stopReason(ctx), exitCode)
+		hooks.finished(fmt.Sprintf("Stopped (%d)", exitCode), false)
+		if exitCode >= 0 {
+			hooks.exited(exitCode)
+		}
+		return nil
+	}
 	if exitCode != 0 {
 		err = errors.Join(err, fmt.Errorf("container returned non-zero exit code %d", exitCode))
 	}
 
 	_, _ = fmt.Fprintf(stdout, "\r\n\r\nContainer exited with code %d\r\n", exitCode)
+	hooks.finished(fmt.Sprintf("Exited (%d)", exitCode), exitCode != 0)
+	hooks.exited(exitCode)
+	if err == nil && written.Load() == 0 && opts.NoOutputNotice {
+		_, _ = fmt.Fprint(stdout, "\033[1mCompleted successfully (no output)\033[0m\r\n")
+	}
 
 	return err
 }
 
+// stopWaitTimeout is how long after the daemon has stopped a container its
+// exit code is waited for.
+const stopWaitTimeout = 5 * time.Second
+
+// errStopped is the cause of cancelling a run on purpose, rather than it
+// failing, wrapped to say why.
+var (
+	errStopped       = errors.New("stopped")
+	errStoppedByUser = fmt.Errorf("%w by user", errStopped)
+)
+
+// errRunEnded is what reading a run's input gives once the run is over.
+var errRunEnded = errors.New("the run has ended")
+
+// attachContainer attaches to the container id, running name, returning a
+// function to do its IO until its output ends, and one to disconnect from it
+// without waiting for that. A nil stderr means it has a TTY. detachKeys are
+// passed on to the daemon, see runOptions.DetachKeys.
+func attachContainer(
+	ctx context.Context,
+	dc dockerClient,
+	id, name, detachKeys string,
+	getTermSize func() (rows, cols uint, err error),
+	resized <-chan struct{},
+	hooks runHooks,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+) (doIO func(context.Context) error, detach func(), err error) {
+	attachOpts := dockerContainer.AttachOptions{
+		Stream:     true,
+		Stdin:      true,
+		Stdout:     true,
+		Stderr:     true,
+		DetachKeys: detachKeys,
+	}
+	log := lifecycleLog.With("container", shortID(id))
+	attached, err := dc.ContainerAttach(ctx, id, attachOpts)
+	if err != nil {
+		log.Error("container attach failed", "err", err)
+		return nil, nil, fmt.Errorf("unable to attach to %s container: %w", name, err)
+	}
+	log.Info("container attached")
+	doIO = hijackedIO(attached, name, log,
+		func(ctx context.Context, r dockerContainer.ResizeOptions) error {
+			return dc.ContainerResize(ctx, id, r)
+		},
+		func(ctx context.Context, s os.Signal) error {
+			return dc.ContainerKill(ctx, id, unix.SignalName(s.(unix.Signal)))
+		},
+		getTermSize, resized, hooks, stdin, stdout, stderr,
+	)
+	return doIO, attached.Close, nil
+}
+
+// hijackedIO returns a function doing the IO of an attached container, or
+// exec, until its output ends, logging to log as it goes.
+func hijackedIO(
+	attached types.HijackedResponse,
+	name string,
+	log *slog.Logger,
+	resizer func(context.Context, dockerContainer.ResizeOptions) error,
+	signaller func(context.Context, os.Signal) error,
+	getTermSize func() (rows, cols uint, err error),
+	resized <-chan struct{},
+	hooks runHooks,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+) func(context.Context) error {
+	return func(ctx context.Context) error {
+		defer attached.Close()
+		err := interactiveTTY(ctx, attached, getTermSize, resized, defaultResizeBackoff,
+			func(ctx context.Context, r dockerContainer.ResizeOptions) error {
+				err := resizer(ctx, r)
+				if err != nil {
+					// expected until the container has started, and retried
+					log.Info("container resize failed", "rows", r.Height, "cols", r.Width, "err", err)
+				} else {
+					log.Info("container resized", "rows", r.Height, "cols", r.Width)
+				}
+				return err
+			},
+			func(ctx context.Context, s os.Signal) error {
+				log.Info("container signalled", "signal", s)
+				return signaller(ctx, s)
+			},
+			func() {
+				log.Info("container first output")
+				hooks.output()
+			},
+			stdin, stdout, stderr,
+		)
+		log.Info("container io ended", "err", err)
+		if err != nil {
+			return fmt.Errorf("failed doing io to %s container: %w", name, err)
+		}
+		return nil
+	}
+}
+
+// attachExisting connects the terminal to a container that is already
+// running, one this app didn't create and so won't stop or remove. Stopping
+// the run just detaches from it.
+func attachExisting(
+	ctx context.Context,
+	dc dockerClient,
+	opts runOptions,
+	getTermSize func() (rows, cols uint, err error),
+	resized <-chan struct{},
+	hooks runHooks,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+) error {
+	info, name, err := runningContainer(ctx, dc, opts.Attach)
+	if err != nil {
+		return err
+	}
+	// the container decided whether it has a TTY when it was created, so its
+	// output has to be taken as it comes
+	if info.Config.Tty {
+		stderr = nil
+	} else if stderr == nil {
+		stderr = stdout
+	}
+
+	attachedAt := time.Now()
+	doIO, detach, err := attachContainer(ctx, dc, info.ID, name, opts.DetachKeys, getTermSize, resized, hooks,
+		stdin, stdout, stderr)
+	if err != nil {
+		return err
+	}
+	if !info.Config.Tty && info.Config.StdinOnce {
+		_, _ = fmt.Fprint(stdout, "Warning: detaching will close the container's input, "+
+			"as it has no TTY and was created to take it only once\r\n")
+	}
+	hooks.created(info.ID, info.Config.Image)
+	// what it writes meanwhile waits in the attached connection, to follow on
+	// from this
+	if err := showEarlierOutput(ctx, dc, info.ID, opts, attachedAt, stdout, stderr); err != nil {
+		_, _ = fmt.Fprintf(stdout, "%v\r\n", err)
+	}
+	_, _ = fmt.Fprintf(stdout, "Attached to container %s (%s)\r\n", name, shortID(info.ID))
+	hooks.status("Attached", false)
+	// the output only ends if the container does, not when we are stopped
+	stopDetach := context.AfterFunc(ctx, detach)
+	defer stopDetach()
+	err = doIO(ctx)
+
+	if ctx.Err() != nil || errors.Is(err, errDetached) {
+		_, _ = fmt.Fprintf(stdout, "\r\n\r\nDetached from container %s\r\n", name)
+		hooks.finished("Detached", false)
+		return nil
+	}
+	// don't hang around for it if it is still going
+	inspectCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if info, iErr := dc.ContainerInspect(inspectCtx, info.ID); iErr == nil && info.State != nil && !info.State.Running {
+		exitCode := info.State.ExitCode
+		_, _ = fmt.Fprintf(stdout, "\r\n\r\nContainer exited with code %d\r\n", exitCode)
+		hooks.finished(fmt.Sprintf("Exited (%d)", exitCode), exitCode != 0)
+		hooks.exited(exitCode)
+		return err
+	}
+	_, _ = fmt.Fprintf(stdout, "\r\n\r\nContainer %s closed its output\r\n", name)
+	hooks.finished("Detached", false)
+	return err
+}
+
+// stopReason says why a run's ctx was cancelled, as an errStopped. Cancelling
+// without a cause is taken to be the user, e.g. closing the app.
+func stopReason(ctx context.Context) error {
+	if cause := context.Cause(ctx); errors.Is(cause, errStopped) {
+		return cause
+	}
+	return errStoppedByUser
+}
+
+// shortID is the abbreviated container ID, as docker ps shows it.
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// byteCounter passes writes through to w, adding how many bytes were written
+// to n.
+type byteCounter struct {
+	w io.Writer
+	n *atomic.Int64
+}
+
+func (c byteCounter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// effectiveHostname reports the fully qualified name the container was given.
+func effectiveHostname(cfg *dockerContainer.Config) string {
+	if cfg.Domainname == "" {
+		return cfg.Hostname
+	}
+	return cfg.Hostname + "." + cfg.Domainname
+}
+
+// resizeBackoff is how interactiveTTY retries resizing the TTY, which fails
+// until the container has started, and may now and then after: after initial,
+// then doubling up to max. Failing for longer than giveUp ends the run. Other
+// calls to the daemon are retried the same way, see retryTransient.
+type resizeBackoff struct {
+	initial, max, giveUp time.Duration
+}
+
+var defaultResizeBackoff = resizeBackoff{
+	initial: 50 * time.Millisecond,
+	max:     2 * time.Second,
+	giveUp:  time.Minute,
+}
+
+// transientRetries is how many times retryTransient tries a call again.
+const transientRetries = 5
+
+// isTransient reports whether a daemon call failed in a way that may well
+// succeed if it is tried again, e.g. as the container is between states,
+// rather than for good.
+func isTransient(err error) bool {
+	return cerrdefs.IsConflict(err) || cerrdefs.IsUnavailable(err)
+}
+
+// retryTransient calls f, trying again while it fails transiently, waiting
+// as backoff says between, up to transientRetries times.
+func retryTransient(ctx context.Context, backoff resizeBackoff, f func() error) error {
+	delay := backoff.initial
+	for retries := 0; ; retries++ {
+		err := f()
+		if err == nil || !isTransient(err) || retries >= transientRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, backoff.max)
+	}
+}
+
+// copyBufferSize is what the container's input and output are copied
+// through, bigger than io.Copy's own so heavy output arrives in fewer writes.
+const copyBufferSize = 64 << 10
+
+// copyBuffers are reused between runs, rather than each copy allocating its
+// own.
+var copyBuffers = sync.Pool{New: func() any {
+	b := make([]byte, copyBufferSize)
+	return &b
+}}
+
+// copyBuffered is io.Copy through a buffer from copyBuffers.
+func copyBuffered(dst io.Writer, src io.Reader) (int64, error) {
+	buf := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buf)
+	return io.CopyBuffer(dst, src, *buf)
+}
+
+// resizeDebounce is how long the terminal widget has to stay the same size
+// before the TTY is resized to match, so that dragging the window's edge
+// doesn't send the daemon a resize for every step.
+const resizeDebounce = 50 * time.Millisecond
+
 func interactiveTTY(
 	ctx context.Context,
 	attached types.HijackedResponse,
 	getTermSize func() (rows, cols uint, err error),
+	resized <-chan struct{},
+	backoff resizeBackoff,
 	resizer func(context.Context, dockerContainer.ResizeOptions) error,
 	signaller func(context.Context, os.Signal) error,
+	onOutput func(),
 	stdin io.Reader,
-	stdout io.Writer,
+	stdout, stderr io.Writer,
 ) error {
+	// a nil stderr means the container has a TTY, otherwise there's no PTY to
+	// resize and the output needs demultiplexing
+	tty := stderr == nil
+
 	// compare to:
 	// https://github.com/docker/cli/blob/master/cli/command/container/run.go
 	// https://github.com/docker/cli/blob/master/cli/command/container/hijack.go
@@ -392,41 +1646,91 @@ func interactiveTTY(
 
 	// 2. setup signal forwarder
 	// 3. setup tty size forwarder
+	// only what is meant for the container, anything else the process gets
+	// (e.g. SIGPIPE, or SIGURG the runtime uses for scheduling) belongs to the
+	// app
 	signals := make(chan os.Signal, 16 /* arbitrary */)
-	signal.Notify(signals)
+	signal.Notify(signals, unix.SIGINT, unix.SIGTERM)
 	defer signal.Stop(signals)
+	// there is only a SIGWINCH with a controlling terminal, the widget
+	// resizing is the usual trigger
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, unix.SIGWINCH)
+	defer signal.Stop(winch)
 	resizeTty := func() error {
-		if w, h, err := getTermSize(); err != nil {
+		if h, w, err := getTermSize(); err != nil {
 			return err
+		} else if h == 0 || w == 0 {
+			// the daemon takes it as is, leaving the TTY unusable until the
+			// next resize, better to leave it as it was
+			lifecycleLog.Warn("zero TTY size not passed on", "rows", h, "cols", w)
+			return nil
 		} else {
 			return resizer(egCtx, dockerContainer.ResizeOptions{Width: w, Height: h})
 		}
 	}
 	eg.Go(func() error {
-		// resize won't work at first, need to retry it until it succeeds
-		resizeRetry := time.NewTicker(100 * time.Millisecond)
-		tryResize := func() {
-			if err := resizeTty(); err == nil {
+		// resize won't work at first, and may fail now and then after, so it
+		// is retried until it succeeds rather than ending the run
+		resizeRetry := time.NewTimer(0)
+		resizeRetry.Stop()
+		settled := time.NewTimer(0)
+		settled.Stop()
+		var delay time.Duration
+		var failingSince time.Time
+		resize := func() error {
+			err := resizeTty()
+			if err == nil || !isTransient(err) {
+				// there's no point retrying e.g. a size the daemon won't take,
+				// nor ending the run over it, the next resize may do better
+				failingSince = time.Time{}
 				resizeRetry.Stop()
+				return nil
+			}
+			if failingSince.IsZero() {
+				failingSince, delay = time.Now(), backoff.initial
+			} else if time.Since(failingSince) > backoff.giveUp {
+				return fmt.Errorf("unable to resize the TTY for over %v: %w", backoff.giveUp, err)
+			}
+			resizeRetry.Reset(delay)
+			delay = min(delay*2, backoff.max)
+			return nil
+		}
+		if tty {
+			if err := resize(); err != nil {
+				return err
 			}
 		}
-		tryResize()
 		for {
 			select {
 			case <-egCtx.Done():
+				resizeRetry.Stop()
+				settled.Stop()
 				return nil
 			case <-resizeRetry.C:
-				tryResize()
-			case s := <-signals:
-				// runtime uses SIGURG for scheduling
-				if s == unix.SIGCHLD || s == unix.SIGPIPE || s == unix.SIGURG {
-					continue
+				if err := resize(); err != nil {
+					return err
+				}
+			case <-resized:
+				// the terminal widget changed size, which is the usual trigger
+				// as GUI window resizes don't raise SIGWINCH
+				if tty {
+					settled.Reset(resizeDebounce)
+				}
+			case <-settled.C:
+				if err := resize(); err != nil {
+					return err
 				}
-				if err := signaller(egCtx, s); err != nil {
+			case s := <-signals:
+				err := retryTransient(egCtx, backoff, func() error { return signaller(egCtx, s) })
+				if err != nil {
 					return fmt.Errorf("failed to forward signal %v: %w", s, err)
 				}
-				if s == unix.SIGWINCH {
-					if err := resizeTty(); err != nil {
+			case <-winch:
+				// not forwarded as such, resizing the TTY raises it in the
+				// container itself
+				if tty {
+					if err := resize(); err != nil {
 						return err
 					}
 				}
@@ -436,16 +1740,23 @@ func interactiveTTY(
 
 	// 4. start routine to copy raw input to attached.Conn
 	eg.Go(func() error {
-		// we can't wait on the input because we can't interrupt the read from stdin
+		// we can't wait on the input because we can't interrupt the read from
+		// stdin, so the copy can outlive this, until the caller ends stdin (as
+		// reallyRun does once the run is over) rather than take what is read
+		// next
 		errCh := make(chan error, 1)
-		// TODO: this is only safe when the parent process isn't going to do
-		// anything else, otherwise this may eat at least one byte from stdin after
-		// the container is terminated
 		go func() {
 			// obeying context cancellation here is hard, because TTY fds don't support
 			// deadlines
-			_, err := io.Copy(attached.Conn, stdin)
-			if errors.Is(err, net.ErrClosed) {
+			_, err := copyBuffered(attached.Conn, stdin)
+			if errors.Is(err, errDetached) {
+				// leave the container's input open, as the container is being
+				// left to carry on, and end the output instead
+				attached.Close()
+				errCh <- err
+				return
+			}
+			if errors.Is(err, net.ErrClosed) || errors.Is(err, errRunEnded) {
 				// ignore this, just means the connection was closed (container stopped)
 				// while we were doing i/o
 				err = nil
@@ -467,9 +1778,18 @@ func interactiveTTY(
 	eg.Go(func() error {
 		// when output ends, everything else should end too
 		defer cancel()
+		var output io.Reader = attached.Reader
+		if onOutput != nil {
+			output = &firstReadNotifier{r: output, onRead: onOutput}
+		}
 		// obeying context cancellation here is hard, because TTY fds don't support
 		// deadlines
-		_, err := io.Copy(stdout, attached.Reader)
+		var err error
+		if tty {
+			_, err = copyBuffered(stdout, output)
+		} else {
+			_, err = stdcopy.StdCopy(stdout, stderr, output)
+		}
 		if errors.Is(err, net.ErrClosed) {
 			// ignore this, just means the connection was closed (container stopped)
 			// while we were doing i/o
@@ -495,9 +1815,3 @@ func interactiveTTY(
 
 	return err
 }
-
-func must(err error) {
-	if err != nil {
-		panic(err)
-	}
-}
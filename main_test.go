@@ -0,0 +1,596 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"runtime"
+	"runtime/pprof"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fyne.io/fyne/v2/test"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types"
+	dockerContainer "github.com/docker/docker/api/types/container"
+	"github.com/fyne-io/terminal"
+	"golang.org/x/sys/unix"
+)
+
+// fakeRun is how a run through runContainer went.
+type fakeRun struct {
+	err      error
+	output   string
+	exitCode int
+	finished string
+	failed   bool
+}
+
+// runFake runs dc's container through runContainer, much as reallyRun
+// would, with a TTY.
+func runFake(ctx context.Context, t *testing.T, dc *fakeDocker, opts runOptions) fakeRun {
+	t.Helper()
+	var mu sync.Mutex
+	run := fakeRun{exitCode: -1}
+	hooks := runHooks{
+		created: func(id, image string) {},
+		status:  func(text string, failed bool) {},
+		output:  func() {},
+		finished: func(text string, failed bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			run.finished, run.failed = text, failed
+		},
+		exited: func(code int) {
+			mu.Lock()
+			defer mu.Unlock()
+			run.exitCode = code
+		},
+		nameInUse: func(name string) nameConflict { return nameConflictCancel },
+	}
+	stdinR, stdinW := io.Pipe()
+	defer stdinW.Close()
+	var out lockedBuffer
+	cfg := &dockerContainer.Config{Image: "fake"}
+	hostCfg := &dockerContainer.HostConfig{AutoRemove: !opts.KeepContainer}
+	getTermSize := func() (uint, uint, error) { return fallbackRows, fallbackCols, nil }
+	err := runContainer(ctx, dc, cfg, hostCfg, nil, opts, getTermSize, nil, hooks, stdinR, &out, nil)
+	_ = stdinR.CloseWithError(errRunEnded)
+
+	mu.Lock()
+	defer mu.Unlock()
+	run.err, run.output = err, out.String()
+	return run
+}
+
+func TestRunContainerWaitRetried(t *testing.T) {
+	dc := newFakeDocker("done\r\n", 3)
+	dc.wait = func(ctx context.Context, id string, call int) (<-chan dockerContainer.WaitResponse, <-chan error) {
+		if call == 0 {
+			errs := make(chan error, 1)
+			errs <- errors.New("unexpected EOF")
+			return nil, errs
+		}
+		return dc.waitExit(ctx)
+	}
+	run := runFake(context.Background(), t, dc, runOptions{})
+
+	if dc.count("ContainerWait") != 2 {
+		t.Errorf("ContainerWait called %d times, want 2", dc.count("ContainerWait"))
+	}
+	if run.exitCode != 3 {
+		t.Errorf("exit code %d, want 3", run.exitCode)
+	}
+	if run.err == nil || !strings.Contains(run.err.Error(), "non-zero exit code 3") {
+		t.Errorf("error %v, want the exit code's", run.err)
+	}
+	if !strings.Contains(run.output, "retrying") {
+		t.Errorf("output %q doesn't say the wait was retried", run.output)
+	}
+}
+
+func TestRunContainerWaitRetriesRunOut(t *testing.T) {
+	dc := newFakeDocker("", 0)
+	dc.wait = func(ctx context.Context, id string, call int) (<-chan dockerContainer.WaitResponse, <-chan error) {
+		errs := make(chan error, 1)
+		errs <- errors.New("unexpected EOF")
+		return nil, errs
+	}
+	run := runFake(context.Background(), t, dc, runOptions{})
+
+	if want := maxWaitRetries + 1; dc.count("ContainerWait") != want {
+		t.Errorf("ContainerWait called %d times, want %d", dc.count("ContainerWait"), want)
+	}
+	if run.err == nil || !strings.Contains(run.err.Error(), "failed waiting for fake container") {
+		t.Errorf("error %v, want the wait's", run.err)
+	}
+	if !dc.called("ContainerRemove " + fakeID) {
+		t.Error("container not removed")
+	}
+}
+
+func TestRunContainerAttachFailureRemoves(t *testing.T) {
+	dc := newFakeDocker("", 0)
+	dc.attach = func(ctx context.Context, id string) (types.HijackedResponse, error) {
+		return types.HijackedResponse{}, errors.New("attach refused")
+	}
+	run := runFake(context.Background(), t, dc, runOptions{})
+
+	if run.err == nil || !strings.Contains(run.err.Error(), "attach refused") {
+		t.Errorf("error %v, want the attach's", run.err)
+	}
+	if !dc.called("ContainerRemove " + fakeID) {
+		t.Errorf("created container not removed, calls: %q", dc.calls)
+	}
+	if dc.count("ContainerStart") != 0 {
+		t.Error("container started though attaching failed")
+	}
+}
+
+// newTestSession is the session of an app on fyne's test driver, running
+// its containers on dc.
+func newTestSession(t *testing.T, dc *fakeDocker) *session {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	s := &AppState{ctx: ctx, app: test.NewTempApp(t)}
+	s.createMainWindow()
+	newDockerClient = func(dockerDaemon) (dockerClient, error) { return dc, nil }
+	t.Cleanup(func() {
+		newDockerClient = func(d dockerDaemon) (dockerClient, error) { return newRawDockerClient(d) }
+	})
+	return s.sessions[0]
+}
+
+// waitFor polls cond until it holds, failing the test if it doesn't within
+// a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReallyRunTeardown(t *testing.T) {
+	dc := newFakeDocker("running\r\n", 0)
+	dc.forever = true
+	sess := newTestSession(t, dc)
+	// the session's own goroutines, e.g. checking the daemon, are already
+	// going, and os/signal starts one for good the first time it is used
+	time.Sleep(100 * time.Millisecond)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, unix.SIGWINCH)
+	signal.Stop(sig)
+	before := runtime.NumGoroutine()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sess.reallyRun(runOptions{Image: "fake"})
+	}()
+	waitFor(t, "the container to start", func() bool { return dc.called("ContainerStart " + fakeID) })
+	sess.stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("run still going after being stopped")
+	}
+	if !dc.called("ContainerRemove " + fakeID) {
+		t.Error("container not removed")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			var stacks strings.Builder
+			_ = pprof.Lookup("goroutine").WriteTo(&stacks, 1)
+			t.Fatalf("%d goroutines before the run, %d after:\n%s", before, runtime.NumGoroutine(), stacks.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRunContainerEndings(t *testing.T) {
+	tests := []struct {
+		name     string
+		exitCode int64
+		// forever leaves the container running until it is stopped
+		forever    bool
+		maxRuntime time.Duration
+		// cancel stops the run once the container has started
+		cancel     bool
+		wantErr    string
+		wantOutput string
+		// finished is what the status ends up as
+		finished string
+		failed   bool
+		// wantExit is what exited is given, the fake's stop exits with 143
+		wantExit int
+	}{
+		{
+			name:       "stopped by user",
+			forever:    true,
+			cancel:     true,
+			wantOutput: "Container stopped by user (exit code 143)",
+			finished:   "Stopped (143)",
+			wantExit:   143,
+		},
+		{
+			name:       "time limit",
+			forever:    true,
+			maxRuntime: 50 * time.Millisecond,
+			wantOutput: "Container stopped after exceeding its time limit of 50ms (exit code 143)",
+			finished:   "Stopped (143)",
+			wantExit:   143,
+		},
+		{
+			name:       "failed",
+			exitCode:   2,
+			wantErr:    "container returned non-zero exit code 2",
+			wantOutput: "Container exited with code 2",
+			finished:   "Exited (2)",
+			failed:     true,
+			wantExit:   2,
+		},
+		{
+			name:       "succeeded",
+			wantOutput: "Container exited with code 0",
+			finished:   "Exited (0)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dc := newFakeDocker("", tt.exitCode)
+			dc.forever = tt.forever
+			ctx, cancel := context.WithCancelCause(context.Background())
+			defer cancel(nil)
+			if tt.cancel {
+				go func() {
+					waitFor(t, "the container to start", func() bool { return dc.called("ContainerStart " + fakeID) })
+					cancel(errStoppedByUser)
+				}()
+			}
+			run := runFake(ctx, t, dc, runOptions{MaxRuntime: tt.maxRuntime})
+
+			if tt.wantErr == "" && run.err != nil {
+				t.Errorf("error %v, want none", run.err)
+			} else if tt.wantErr != "" && (run.err == nil || !strings.Contains(run.err.Error(), tt.wantErr)) {
+				t.Errorf("error %v, want %q", run.err, tt.wantErr)
+			}
+			if !strings.Contains(run.output, tt.wantOutput) {
+				t.Errorf("output %q, want %q in it", run.output, tt.wantOutput)
+			}
+			if run.finished != tt.finished || run.failed != tt.failed {
+				t.Errorf("finished %q (failed %v), want %q (failed %v)", run.finished, run.failed, tt.finished, tt.failed)
+			}
+			if run.exitCode != tt.wantExit {
+				t.Errorf("exited with %d, want %d", run.exitCode, tt.wantExit)
+			}
+		})
+	}
+}
+
+// resizeRecorder records the sizes interactiveTTY resizes the TTY to, and
+// when, failing with fail's error if it gives one.
+type resizeRecorder struct {
+	mu    sync.Mutex
+	sizes []string
+	times []time.Time
+	fail  func(call int) error
+}
+
+func (r *resizeRecorder) resize(_ context.Context, o dockerContainer.ResizeOptions) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	call := len(r.times)
+	r.times = append(r.times, time.Now())
+	if r.fail != nil {
+		if err := r.fail(call); err != nil {
+			return err
+		}
+	}
+	r.sizes = append(r.sizes, fmt.Sprintf("%dx%d", o.Height, o.Width))
+	return nil
+}
+
+func (r *resizeRecorder) resized() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.sizes)
+}
+
+// startTTY runs interactiveTTY on a pretend container's TTY, sized by the
+// terminal tracker follows, until the returned function ends its output,
+// which then returns what interactiveTTY did.
+func startTTY(t *testing.T, tracker *termSizeTracker, backoff resizeBackoff, r *resizeRecorder) func() error {
+	t.Helper()
+	return startTTYSized(t, func() (uint, uint, error) {
+		rows, cols := tracker.LastSize()
+		return rows, cols, nil
+	}, tracker.Changed(), backoff, r)
+}
+
+func startTTYSized(
+	t *testing.T,
+	getTermSize func() (uint, uint, error),
+	resized <-chan struct{},
+	backoff resizeBackoff,
+	r *resizeRecorder,
+) func() error {
+	t.Helper()
+	ours, theirs := net.Pipe()
+	go func() { _, _ = io.Copy(io.Discard, theirs) }()
+	stdinR, stdinW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- interactiveTTY(context.Background(), types.NewHijackedResponse(ours, ""), getTermSize, resized,
+			backoff, r.resize, nil, nil, stdinR, io.Discard, nil)
+	}()
+	return func() error {
+		_ = theirs.Close()
+		_ = stdinW.Close()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("TTY still going after its output ended")
+			return nil
+		}
+	}
+}
+
+// newTestTracker is a termSizeTracker, which has been told the terminal is
+// rows by cols.
+func newTestTracker(t *testing.T, rows, cols uint) *termSizeTracker {
+	t.Helper()
+	test.NewTempApp(t)
+	tracker := newTermSizeTracker(terminal.New())
+	tracker.ch <- terminal.Config{Rows: rows, Columns: cols}
+	// a run takes the first size as it starts, not as a change
+	select {
+	case <-tracker.Changed():
+	case <-time.After(time.Second):
+		t.Fatal("tracker didn't take the terminal's size")
+	}
+	return tracker
+}
+
+func TestInteractiveTTYResizeDebounced(t *testing.T) {
+	tracker := newTestTracker(t, 24, 80)
+	r := &resizeRecorder{}
+	stop := startTTY(t, tracker, defaultResizeBackoff, r)
+	waitFor(t, "the first resize", func() bool { return len(r.resized()) == 1 })
+
+	// as if the window's edge was being dragged
+	for i := range uint(5) {
+		tracker.ch <- terminal.Config{Rows: 30 + i, Columns: 100 + i}
+		time.Sleep(resizeDebounce / 10)
+	}
+	waitFor(t, "the resize after dragging", func() bool { return len(r.resized()) >= 2 })
+	time.Sleep(4 * resizeDebounce)
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.resized(), []string{"24x80", "34x104"}; !slices.Equal(got, want) {
+		t.Errorf("resized to %q, want %q", got, want)
+	}
+}
+
+func TestRunContainerDrainsOutputOnExit(t *testing.T) {
+	dc := newFakeDocker("", 0)
+	dc.forever = true
+	// the daemon can report the exit before the last of the output is through
+	dc.wait = func(ctx context.Context, id string, call int) (<-chan dockerContainer.WaitResponse, <-chan error) {
+		stopped := make(chan dockerContainer.WaitResponse, 1)
+		stopped <- dockerContainer.WaitResponse{StatusCode: 0}
+		return stopped, nil
+	}
+	go func() {
+		waitFor(t, "the container to start", func() bool { return dc.called("ContainerStart " + fakeID) })
+		time.Sleep(50 * time.Millisecond)
+		dc.mu.Lock()
+		conn := dc.conn
+		dc.mu.Unlock()
+		_, _ = io.WriteString(conn, "Z")
+		dc.exit(0)
+	}()
+	run := runFake(context.Background(), t, dc, runOptions{})
+
+	if run.err != nil {
+		t.Fatal(run.err)
+	}
+	last := strings.Index(run.output, "Z")
+	exited := strings.Index(run.output, "Container exited with code 0")
+	if last < 0 || exited < 0 || last > exited {
+		t.Errorf("output %q doesn't have the last byte before the exit", run.output)
+	}
+}
+
+func TestInteractiveTTYResizeBackoff(t *testing.T) {
+	tracker := newTestTracker(t, 24, 80)
+	backoff := resizeBackoff{initial: 20 * time.Millisecond, max: 80 * time.Millisecond, giveUp: time.Minute}
+	r := &resizeRecorder{fail: func(call int) error {
+		// until the container has started, then once more later on
+		if call < 4 || call == 5 {
+			return cerrdefs.ErrUnavailable
+		}
+		return nil
+	}}
+	stop := startTTY(t, tracker, backoff, r)
+	waitFor(t, "the first resize to succeed", func() bool { return len(r.resized()) == 1 })
+	tracker.ch <- terminal.Config{Rows: 30, Columns: 100}
+	waitFor(t, "the second resize to succeed", func() bool { return len(r.resized()) == 2 })
+	if err := stop(); err != nil {
+		t.Fatalf("resizing failing now and then ended the run: %v", err)
+	}
+
+	if got, want := r.resized(), []string{"24x80", "30x100"}; !slices.Equal(got, want) {
+		t.Errorf("resized to %q, want %q", got, want)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// doubling from initial up to max, between the attempts that failed
+	for i, want := range []time.Duration{20, 40, 80, 80} {
+		want *= time.Millisecond
+		if gap := r.times[i+1].Sub(r.times[i]); gap < want {
+			t.Errorf("retry %d after %v, want at least %v", i+1, gap, want)
+		}
+	}
+	if gap, want := r.times[6].Sub(r.times[5]), backoff.initial; gap < want {
+		t.Errorf("retry of a later resize after %v, want the backoff to start again from %v", gap, want)
+	}
+}
+
+func TestInteractiveTTYResizeGivesUp(t *testing.T) {
+	tracker := newTestTracker(t, 24, 80)
+	backoff := resizeBackoff{initial: 10 * time.Millisecond, max: 20 * time.Millisecond, giveUp: 100 * time.Millisecond}
+	r := &resizeRecorder{fail: func(int) error { return cerrdefs.ErrUnavailable }}
+	stop := startTTY(t, tracker, backoff, r)
+	time.Sleep(3 * backoff.giveUp)
+	if err := stop(); err == nil || !strings.Contains(err.Error(), "unable to resize the TTY") {
+		t.Errorf("error %v, want the resize's", err)
+	}
+}
+
+func TestInteractiveTTYNeverResizesToZero(t *testing.T) {
+	tracker := newTestTracker(t, 24, 80)
+	r := &resizeRecorder{}
+	stop := startTTY(t, tracker, defaultResizeBackoff, r)
+	waitFor(t, "the first resize", func() bool { return len(r.resized()) == 1 })
+	// as the widget reports while its window is minimized
+	for _, cfg := range []terminal.Config{{Rows: 0, Columns: 0}, {Rows: 0, Columns: 80}, {Rows: 24, Columns: 0}} {
+		tracker.ch <- cfg
+	}
+	time.Sleep(4 * resizeDebounce)
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.resized(), []string{"24x80"}; !slices.Equal(got, want) {
+		t.Errorf("resized to %q, want %q", got, want)
+	}
+	if rows, cols := tracker.LastSize(); rows != 24 || cols != 80 {
+		t.Errorf("tracker has %dx%d, want the last real size 24x80", rows, cols)
+	}
+}
+
+func TestInteractiveTTYZeroSizeNotPassedOn(t *testing.T) {
+	for _, size := range [][2]uint{{0, 0}, {0, 80}, {24, 0}} {
+		r := &resizeRecorder{}
+		resized := make(chan struct{}, 1)
+		stop := startTTYSized(t, func() (uint, uint, error) { return size[0], size[1], nil }, resized, defaultResizeBackoff, r)
+		resized <- struct{}{}
+		time.Sleep(4 * resizeDebounce)
+		if err := stop(); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.resized(); len(got) > 0 {
+			t.Errorf("a %dx%d terminal resized the TTY to %q", size[0], size[1], got)
+		}
+	}
+}
+
+// BenchmarkCopyBuffered is copyBuffered against io.Copy, for what each copy of
+// a run's output allocates. The reader and writer are plain, as the hijacked
+// connection and the output pipeline are, so neither copy can go around its
+// buffer.
+func BenchmarkCopyBuffered(b *testing.B) {
+	for _, c := range []struct {
+		name string
+		copy func(io.Writer, io.Reader) (int64, error)
+	}{
+		{"io.Copy", io.Copy},
+		{"copyBuffered", copyBuffered},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(benchOutput)))
+			for b.Loop() {
+				src := struct{ io.Reader }{bytes.NewReader(benchOutput)}
+				dst := struct{ io.Writer }{io.Discard}
+				if _, err := c.copy(dst, src); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestStartDaemonUnreachable checks that a run started other than from the
+// Run button, e.g. by a shortcut, is refused while the daemon can't be reached.
+func TestStartDaemonUnreachable(t *testing.T) {
+	dc := newFakeDocker("", 0)
+	sess := newTestSession(t, dc)
+	sess.stopCheck()
+	sess.daemonErr = errors.New("connection refused")
+	var abandoned error
+	sess.exitWhenDone = func(_ int, err error) { abandoned = err }
+	sess.start(runOptions{Image: "fake"})
+	if abandoned == nil || !strings.Contains(abandoned.Error(), "connection refused") {
+		t.Errorf("run abandoned with %v, want the daemon's error", abandoned)
+	}
+	if sess.running.Load() {
+		t.Error("session left running")
+	}
+	if n := dc.count("ContainerCreate"); n != 0 {
+		t.Errorf("%d containers created", n)
+	}
+}
+
+// TestPauseUnseen checks that only the selected session's output is held
+// back, and only while the window is minimised.
+func TestPauseUnseen(t *testing.T) {
+	first := newTestSession(t, newFakeDocker("", 0))
+	s := first.AppState
+	s.addSession()
+	second := s.current()
+	check := func(when string, wantFirst, wantSecond bool) {
+		t.Helper()
+		for _, c := range []struct {
+			sess *session
+			want bool
+		}{{first, wantFirst}, {second, wantSecond}} {
+			c.sess.background.mu.Lock()
+			paused := c.sess.background.paused
+			c.sess.background.mu.Unlock()
+			if paused != c.want {
+				t.Errorf("%s: %s paused %v, want %v", when, c.sess.name, paused, c.want)
+			}
+		}
+	}
+	check("second selected", true, false)
+	s.tabs.Select(first.tab)
+	check("first selected", false, true)
+	s.minimised = true
+	s.pauseUnseen()
+	check("minimised", true, true)
+	s.minimised = false
+	s.closeSession(first)
+	check("first closed", true, false)
+}
+
+// TestRunContainerDetachKeys checks that the daemon is given the configured
+// detach keys, so that it doesn't also watch for its own.
+func TestRunContainerDetachKeys(t *testing.T) {
+	dc := newFakeDocker("", 0)
+	run := runFake(context.Background(), t, dc, runOptions{DetachKeys: "ctrl-x,ctrl-y"})
+	if run.err != nil {
+		t.Fatal(run.err)
+	}
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if got := dc.attachOpts; !got.Stdin || got.DetachKeys != "ctrl-x,ctrl-y" {
+		t.Errorf("attached with stdin %v, detach keys %q, want stdin and %q", got.Stdin, got.DetachKeys, "ctrl-x,ctrl-y")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+)
+
+// builtinNetworks are the network modes every daemon has, anything else names
+// a network the user has created.
+var builtinNetworks = []string{network.NetworkBridge, network.NetworkHost, network.NetworkNone}
+
+// networkingConfig connects the container to the named network, if it is one
+// the user created, for ContainerCreate. The built in modes, and sharing
+// another container's network, only need the host config's NetworkMode.
+//
+// A network that doesn't exist is only warned about in out, the daemon
+// refuses to create the container anyway and says why.
+func networkingConfig(ctx context.Context, dc dockerClient, name string, out io.Writer) (*network.NetworkingConfig, error) {
+	if name == "" || slices.Contains(builtinNetworks, name) || strings.HasPrefix(name, "container:") {
+		return nil, nil
+	}
+	// the filter matches any part of the name, or the start of the ID
+	found, err := dc.NetworkList(ctx, network.ListOptions{Filters: filters.NewArgs(filters.Arg("name", name))})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list networks: %w", err)
+	}
+	if !slices.ContainsFunc(found, func(n network.Summary) bool { return n.Name == name || n.ID == name }) {
+		_, _ = fmt.Fprintf(out, "Warning: there is no network %q, create it with docker network create\r\n", name)
+	}
+	return &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{name: {}},
+	}, nil
+}
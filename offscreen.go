@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"io"
+
+	"fyne.io/fyne/v2"
+)
+
+// prefDropOffscreen is whether lines that would scroll straight off the
+// terminal are left out of it.
+const prefDropOffscreen = "output.dropOffscreen"
+
+func dropOffscreen(prefs fyne.Preferences) bool {
+	return prefs.BoolWithFallback(prefDropOffscreen, true)
+}
+
+// offscreenDropper leaves out the lines of each write that would only scroll
+// straight off the top of the terminal, as the widget redraws its whole grid
+// for every line it scrolls by, which is what makes heavy output slow. The
+// widget keeps no scrollback, so they would never be seen there; the full
+// output goes to the scrollback view and transcript separately.
+//
+// Only plain lines are dropped: text, CR LF, tabs, backspaces and SGR
+// (colour etc) sequences, the last of which are still passed on so the
+// attributes the remaining lines start with are the same. Anything else, such
+// as cursor movement, is passed on as is and the lines before it kept. While a
+// scroll region is set nothing is dropped, as line feeds then don't scroll the
+// whole screen.
+type offscreenDropper struct {
+	w io.Writer
+	// rows is the terminal's height, or 0 if that isn't known
+	rows func() uint
+
+	scanner   ansiScanner
+	regionSet bool
+	out       []byte
+	// run is the plain tokens since the last other one, as offsets into out
+	run []runToken
+}
+
+type runToken struct {
+	kind       tokenKind
+	start, end int
+}
+
+func newOffscreenDropper(w io.Writer, rows func() uint) *offscreenDropper {
+	return &offscreenDropper{w: w, rows: rows}
+}
+
+func (d *offscreenDropper) Write(p []byte) (int, error) {
+	d.out = d.out[:0]
+	d.run = d.run[:0]
+	rows := int(d.rows())
+	d.scanner.scan(p, func(kind tokenKind, tok []byte) {
+		if !d.plain(kind, tok) {
+			d.endRun(rows)
+			d.track(kind, tok)
+			d.out = append(d.out, tok...)
+			return
+		}
+		d.run = append(d.run, runToken{kind, len(d.out), len(d.out) + len(tok)})
+		d.out = append(d.out, tok...)
+	})
+	d.endRun(rows)
+	if _, err := d.w.Write(d.out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush passes on anything held back waiting for the rest of a sequence.
+func (d *offscreenDropper) Flush() error {
+	var err error
+	d.scanner.flush(func(_ tokenKind, tok []byte) {
+		_, err = d.w.Write(tok)
+	})
+	return err
+}
+
+func (d *offscreenDropper) plain(kind tokenKind, tok []byte) bool {
+	switch kind {
+	case tokenText:
+		return true
+	case tokenControl:
+		return tok[0] == '\r' || tok[0] == '\n' || tok[0] == '\t' || tok[0] == '\b'
+	default:
+		return isSGR(tok)
+	}
+}
+
+// track follows whether a scroll region is set.
+func (d *offscreenDropper) track(kind tokenKind, tok []byte) {
+	if kind != tokenEscape {
+		return
+	}
+	switch {
+	case len(tok) >= 3 && tok[1] == '[' && tok[len(tok)-1] == 'r':
+		// DECSTBM, without parameters it goes back to the whole screen
+		d.regionSet = len(tok) > 3
+	case bytes.Equal(tok, []byte("\x1bc")):
+		d.regionSet = false
+	}
+}
+
+// endRun drops the part of the current run that the rest of it scrolls off
+// the screen. rows line feeds would scroll them off from wherever the cursor
+// is, but those starting above the bottom only move the cursor down over what
+// is already there, which isn't cleared, so twice that many are needed for
+// the screen to end up as it would have. The cut is made at a CR LF, so that
+// the cursor is at the start of a line either way.
+func (d *offscreenDropper) endRun(rows int) {
+	run := d.run
+	d.run = d.run[:0]
+	if rows <= 0 || d.regionSet || len(run) == 0 {
+		return
+	}
+	cut := -1
+	feeds := 0
+	for i := len(run) - 1; i > 0; i-- {
+		if !d.isControl(run[i], '\n') {
+			continue
+		}
+		if feeds++; feeds >= 2*rows && d.isControl(run[i-1], '\r') {
+			cut = i - 1
+			break
+		}
+	}
+	if cut <= 0 {
+		return
+	}
+	// keep the attributes set in the dropped lines, then the rest as is
+	first := run[0].start
+	kept := d.out[:first:first]
+	for _, t := range run[:cut] {
+		if t.kind == tokenEscape {
+			kept = append(kept, d.out[t.start:t.end]...)
+		}
+	}
+	d.out = append(kept, d.out[run[cut].start:]...)
+}
+
+func (d *offscreenDropper) isControl(t runToken, c byte) bool {
+	return t.kind == tokenControl && d.out[t.start] == c
+}
+
+// isSGR reports whether tok is a CSI ... m, setting character attributes.
+func isSGR(tok []byte) bool {
+	if len(tok) < 3 || tok[1] != '[' || tok[len(tok)-1] != 'm' {
+		return false
+	}
+	for _, c := range tok[2 : len(tok)-1] {
+		if (c < '0' || c > '9') && c != ';' && c != ':' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"fyne.io/fyne/v2/test"
+)
+
+// BenchmarkOffscreenDropper is how fast a 24 row terminal takes output
+// written in big pieces, as it is once batched, with the dropper off (0 rows)
+// and on.
+func BenchmarkOffscreenDropper(b *testing.B) {
+	test.NewTempApp(b)
+	for _, rows := range []uint{0, 24} {
+		b.Run(fmt.Sprintf("%drows", rows), func(b *testing.B) {
+			b.SetBytes(int64(len(benchOutput)))
+			for b.Loop() {
+				sink, wait := terminalSink(b)
+				d := newOffscreenDropper(sink, func() uint { return rows })
+				for out := benchOutput; len(out) > 0; {
+					n := min(len(out), outputBatchMaxBytes/2)
+					if _, err := d.Write(out[:n]); err != nil {
+						b.Fatal(err)
+					}
+					out = out[n:]
+				}
+				if err := d.Flush(); err != nil {
+					b.Fatal(err)
+				}
+				_ = sink.Close()
+				wait()
+			}
+		})
+	}
+}
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/blkiodev"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/go-connections/nat"
+	"github.com/docker/go-units"
+	"golang.org/x/sys/unix"
+)
+
+// runOptions holds the user-configurable settings for a single container run.
+// The zero value runs the demo workload with Docker's defaults.
+type runOptions struct {
+	// DockerHost is the daemon to run on, e.g. tcp://host:2376, instead of the
+	// one DOCKER_HOST (or the default socket) gives.
+	DockerHost string
+	// DockerTLS is how to connect to it over TLS.
+	DockerTLS dockerTLS
+	// Replay is a recording to play back into the terminal, ReplaySpeed times
+	// as fast as it was recorded (0 for as fast as possible), instead of
+	// running anything. Neither is saved, a replay isn't a run to repeat.
+	Replay      string  `json:"-"`
+	ReplaySpeed float64 `json:"-"`
+	// Attach is the ID or name of a running container to attach to, instead of
+	// creating one. What the container runs, and how, is then its own business
+	// so the options for that are ignored.
+	Attach string
+	// Exec, with Attach, runs Cmd (or Commands, or a shell if neither is
+	// given) in that container, like docker exec, instead of attaching to it.
+	Exec bool
+	// LogTail and LogSince, with Attach, show that much of the container's
+	// earlier output before attaching to it: its last LogTail lines ("all"
+	// for every one), of what it wrote in the last LogSince. Neither set shows
+	// none.
+	LogTail  string
+	LogSince time.Duration
+	// DetachKeys is the sequence, in --detach-keys format, that leaves the
+	// container running. It is the app's setting, filled in for each run, so
+	// isn't saved with the rest.
+	DetachKeys string `json:"-"`
+
+	// Hostname and Domainname override what the container sees, Docker assigns a
+	// hostname if these are left empty.
+	Hostname   string
+	Domainname string
+	// Name is the container's, which Docker makes up if it is left empty. It
+	// must not be one already in use, see createNamed.
+	Name string
+	// Labels are set on the container, e.g. to find it with docker ps
+	// --filter label=KEY, along with appLabel.
+	Labels map[string]string
+
+	// Image replaces the demo image, running its own command unless Cmd is set.
+	// Leaving it empty, or as defaultImage, runs the demo workload.
+	// Entrypoint, if set, overrides the image's.
+	Image      string
+	Cmd        []string
+	Entrypoint []string
+	// Env is added to the container's environment, taking precedence over
+	// anything forwarded from the host.
+	Env []string
+	// WorkingDir and User, as a name or uid[:gid], replace the image's when
+	// set.
+	WorkingDir string
+	User       string
+
+	// BuildContext, if set, is a directory whose image is built, and run in place
+	// of the demo image.
+	BuildContext string
+	// Dockerfile is the file in BuildContext to build, its Dockerfile if empty.
+	Dockerfile string
+
+	// Commands, if set, replaces the demo workload with a script that runs each
+	// command in turn, stopping at the first failure if StopOnFailure is set.
+	Commands      []string
+	StopOnFailure bool
+
+	// SplitStreams runs the container without a TTY so that stdout and stderr
+	// can be shown in separate panes.
+	SplitStreams bool
+
+	// NoOutputNotice says so when a run succeeds without writing anything, so
+	// that it doesn't look like nothing happened.
+	NoOutputNotice bool
+
+	// ForwardLocale passes the host's TZ, LANG and LC_* settings, and its
+	// /etc/localtime, on to the container so times and formatting match.
+	ForwardLocale bool
+
+	// IdleTimeout, if set, stops the container once there has been no input
+	// for that long.
+	IdleTimeout time.Duration
+	// MaxRuntime, if set, stops the container once it has been running for
+	// that long.
+	MaxRuntime time.Duration
+
+	// StopSignal and StopTimeout (in seconds) control how the container is asked
+	// to stop before it is killed, empty or nil leave the image's defaults.
+	StopSignal  string
+	StopTimeout *int
+
+	// Memory (in bytes) and NanoCPUs cap the container's resources, 0 leaves
+	// it unlimited.
+	Memory   int64
+	NanoCPUs int64
+
+	// BlkioWeight is the relative block IO weight, 0 leaves the daemon default.
+	// The per device lists override the weight, or cap the rate in bytes per
+	// second, for individual devices.
+	BlkioWeight         uint16
+	BlkioWeightDevice   []*blkiodev.WeightDevice
+	BlkioDeviceReadBps  []*blkiodev.ThrottleDevice
+	BlkioDeviceWriteBps []*blkiodev.ThrottleDevice
+
+	// KeepContainer leaves the container once it exits, rather than removing
+	// it, so that its logs and state can be inspected.
+	KeepContainer bool
+
+	// Privileged gives the container all of the host's devices and
+	// capabilities, which few workloads need.
+	Privileged bool
+	// ReadonlyRootfs mounts the container's root filesystem read-only, so that
+	// only Volumes and Tmpfs can be written to.
+	ReadonlyRootfs bool
+
+	// OomScoreAdj, if set, adjusts how likely the kernel is to pick the
+	// container's processes when out of memory, from -1000 (never) to 1000.
+	// OomKillDisable stops the OOM killer acting on the container at all.
+	OomScoreAdj    *int
+	OomKillDisable bool
+
+	// Volumes are named volume mounts, any that don't exist are created once the
+	// user confirms it, and bind mounts of host paths, which must exist.
+	Volumes []mount.Mount
+	// Tmpfs are the container paths to mount a tmpfs on, each with its mount
+	// options, e.g. size=64m, which may be empty.
+	Tmpfs map[string]string
+
+	// Network is the network mode, bridge, host or none, or the name of a
+	// network to connect to. Docker's default, bridge, if empty.
+	Network string
+	// ExtraHosts are added to the container's /etc/hosts, each as HOST:IP.
+	ExtraHosts []string
+
+	// ExposedPorts and PortBindings publish container ports on the host.
+	ExposedPorts nat.PortSet
+	PortBindings nat.PortMap
+}
+
+// daemon is the daemon the run is to connect to.
+func (o runOptions) daemon() dockerDaemon {
+	return dockerDaemon{host: o.DockerHost, tls: o.DockerTLS}
+}
+
+func (o runOptions) validate() error {
+	if o.Replay != "" {
+		if err := readableFile(o.Replay); err != nil {
+			return fmt.Errorf("unable to replay: %w", err)
+		}
+		if o.ReplaySpeed < 0 {
+			return fmt.Errorf("invalid replay speed %v: must not be negative", o.ReplaySpeed)
+		}
+		return nil
+	}
+	if o.DockerHost != "" {
+		if err := validateDockerHost(o.DockerHost); err != nil {
+			return fmt.Errorf("invalid docker host %q: %w", o.DockerHost, err)
+		}
+	}
+	if err := o.DockerTLS.validate(); err != nil {
+		return fmt.Errorf("invalid docker TLS settings: %w", err)
+	}
+	if o.Image != "" && o.Attach == "" && o.BuildContext == "" {
+		if _, err := reference.ParseNormalizedNamed(o.Image); err != nil {
+			return fmt.Errorf("invalid image %q: %w", o.Image, err)
+		}
+	}
+	if err := validateLogTail(o.LogTail); err != nil {
+		return fmt.Errorf("invalid earlier output lines %q: %w", o.LogTail, err)
+	}
+	if o.LogSince < 0 {
+		return fmt.Errorf("invalid earlier output period %v: must not be negative", o.LogSince)
+	}
+	if o.Hostname != "" {
+		if err := validateDNSName(o.Hostname); err != nil {
+			return fmt.Errorf("invalid hostname %q: %w", o.Hostname, err)
+		}
+	}
+	if o.Domainname != "" {
+		if err := validateDNSName(o.Domainname); err != nil {
+			return fmt.Errorf("invalid domain name %q: %w", o.Domainname, err)
+		}
+	}
+	if o.Name != "" {
+		if err := validateContainerName(o.Name); err != nil {
+			return err
+		}
+	}
+	if o.WorkingDir != "" && !path.IsAbs(o.WorkingDir) {
+		return fmt.Errorf("invalid working directory %q: must be an absolute path", o.WorkingDir)
+	}
+	if o.User != "" {
+		if err := validateUser(o.User); err != nil {
+			return fmt.Errorf("invalid user %q: %w", o.User, err)
+		}
+	}
+	if o.BuildContext != "" {
+		if info, err := os.Stat(o.BuildContext); err != nil {
+			return fmt.Errorf("invalid build context: %w", err)
+		} else if !info.IsDir() {
+			return fmt.Errorf("invalid build context: %s is not a directory", o.BuildContext)
+		}
+	}
+	if o.Dockerfile != "" {
+		if o.BuildContext == "" {
+			return errors.New("a Dockerfile needs a build context to build it in")
+		}
+		if !filepath.IsLocal(o.Dockerfile) {
+			return fmt.Errorf("invalid Dockerfile %q: must be a path inside the build context", o.Dockerfile)
+		}
+		if info, err := os.Stat(filepath.Join(o.BuildContext, o.Dockerfile)); err != nil {
+			return fmt.Errorf("invalid Dockerfile: %w", err)
+		} else if info.IsDir() {
+			return fmt.Errorf("invalid Dockerfile: %s is a directory", o.Dockerfile)
+		}
+	}
+	if o.StopSignal != "" {
+		if _, err := parseSignal(o.StopSignal); err != nil {
+			return fmt.Errorf("invalid stop signal: %w", err)
+		}
+	}
+	if o.StopTimeout != nil && *o.StopTimeout < -1 {
+		return fmt.Errorf("invalid stop timeout %d: must be at least -1 (wait forever)", *o.StopTimeout)
+	}
+	if o.IdleTimeout < 0 {
+		return fmt.Errorf("invalid idle timeout %v: must not be negative", o.IdleTimeout)
+	}
+	if o.MaxRuntime < 0 {
+		return fmt.Errorf("invalid max runtime %v: must not be negative", o.MaxRuntime)
+	}
+	if o.Memory != 0 && o.Memory < minMemory {
+		return fmt.Errorf("invalid memory limit %s: must be at least %s", units.BytesSize(float64(o.Memory)), units.BytesSize(minMemory))
+	}
+	if o.NanoCPUs < 0 {
+		return fmt.Errorf("invalid CPU limit %g: must be more than 0", float64(o.NanoCPUs)/1e9)
+	}
+	if o.BlkioWeight != 0 {
+		if err := validateBlkioWeight(o.BlkioWeight); err != nil {
+			return fmt.Errorf("invalid block IO weight: %w", err)
+		}
+	}
+	if o.OomScoreAdj != nil && (*o.OomScoreAdj < -1000 || *o.OomScoreAdj > 1000) {
+		return fmt.Errorf("invalid OOM score adjustment %d: must be from -1000 to 1000", *o.OomScoreAdj)
+	}
+	if missing := missingBindSources(o.Volumes); len(missing) > 0 {
+		return fmt.Errorf("bind mount host paths do not exist:\n%s", strings.Join(missing, "\n"))
+	}
+	for p := range o.Tmpfs {
+		if !path.IsAbs(p) {
+			return fmt.Errorf("invalid tmpfs %q: mount point is not absolute", p)
+		}
+	}
+	for _, h := range o.ExtraHosts {
+		if err := validateExtraHost(h); err != nil {
+			return fmt.Errorf("invalid extra host %q: %w", h, err)
+		}
+	}
+	for _, d := range o.BlkioWeightDevice {
+		if err := validateBlkioWeight(d.Weight); err != nil {
+			return fmt.Errorf("invalid block IO weight for %s: %w", d.Path, err)
+		}
+	}
+	return nil
+}
+
+// validateLogTail checks tail is a number of lines, or "all", as docker logs
+// --tail takes.
+func validateLogTail(tail string) error {
+	if tail == "" || tail == "all" {
+		return nil
+	}
+	if n, err := strconv.Atoi(tail); err != nil || n < 0 {
+		return errors.New(`must be a number of lines, or "all"`)
+	}
+	return nil
+}
+
+func validateBlkioWeight(w uint16) error {
+	if w < 10 || w > 1000 {
+		return fmt.Errorf("%d is not in the range 10 to 1000", w)
+	}
+	return nil
+}
+
+// parseDeviceLines splits lines of the form PATH:VALUE, as used by the docker
+// CLI's --device-* flags, calling parse on each.
+func parseDeviceLines(text string, parse func(path, value string) error) error {
+	for _, line := range nonEmptyLines(text) {
+		path, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return fmt.Errorf("%q is not of the form PATH:VALUE", line)
+		}
+		if !strings.HasPrefix(path, "/dev/") {
+			return fmt.Errorf("%q is not a device path under /dev/", path)
+		}
+		if err := parse(path, strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("%q: %w", line, err)
+		}
+	}
+	return nil
+}
+
+func parseWeightDevices(text string) ([]*blkiodev.WeightDevice, error) {
+	var devices []*blkiodev.WeightDevice
+	err := parseDeviceLines(text, func(path, value string) error {
+		w, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return err
+		}
+		devices = append(devices, &blkiodev.WeightDevice{Path: path, Weight: uint16(w)})
+		return nil
+	})
+	return devices, err
+}
+
+// parseThrottleDevices parses rates such as 10mb, in bytes per second.
+func parseThrottleDevices(text string) ([]*blkiodev.ThrottleDevice, error) {
+	var devices []*blkiodev.ThrottleDevice
+	err := parseDeviceLines(text, func(path, value string) error {
+		rate, err := units.RAMInBytes(value)
+		if err != nil {
+			return err
+		}
+		if rate <= 0 {
+			return errors.New("rate must be positive")
+		}
+		devices = append(devices, &blkiodev.ThrottleDevice{Path: path, Rate: uint64(rate)})
+		return nil
+	})
+	return devices, err
+}
+
+// parseSignal accepts a signal name, with or without the SIG prefix, or number
+// and returns the name as used when forwarding signals to the container.
+func parseSignal(s string) (string, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if n, err := strconv.Atoi(s); err == nil {
+		if name := unix.SignalName(syscall.Signal(n)); name != "" {
+			return name, nil
+		}
+		return "", fmt.Errorf("unknown signal %d", n)
+	}
+	if !strings.HasPrefix(s, "SIG") {
+		s = "SIG" + s
+	}
+	if unix.SignalNum(s) == 0 {
+		return "", fmt.Errorf("unknown signal %q", s)
+	}
+	return s, nil
+}
+
+// validateUser checks for a user, and optionally a group, as a name or a
+// numeric ID, e.g. "builder" or "1000:1000".
+func validateUser(user string) error {
+	name, group, hasGroup := strings.Cut(user, ":")
+	if name == "" {
+		return errors.New("no user given")
+	}
+	if hasGroup && group == "" {
+		return errors.New("no group given after the colon")
+	}
+	if strings.ContainsAny(user, " \t") || strings.Count(user, ":") > 1 {
+		return errors.New("must be a name or uid, with an optional :group or :gid")
+	}
+	return nil
+}
+
+// parseExtraHosts parses lines of HOST:IP for the container's /etc/hosts.
+func parseExtraHosts(text string) ([]string, error) {
+	hosts := nonEmptyLines(text)
+	for _, h := range hosts {
+		if err := validateExtraHost(h); err != nil {
+			return nil, fmt.Errorf("%q: %w", h, err)
+		}
+	}
+	return hosts, nil
+}
+
+// parseLabels parses lines of KEY=VALUE, a value may be empty, and a line of
+// just a KEY is taken to have an empty one as docker run's --label does.
+func parseLabels(text string) (map[string]string, error) {
+	var labels map[string]string
+	for _, line := range nonEmptyLines(text) {
+		key, value, _ := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("%q has no label key", line)
+		}
+		if strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("label key %q contains spaces", key)
+		}
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// validateExtraHost checks for HOST:IP, as docker run's --add-host takes. The
+// IP may be an IPv6 one, or host-gateway for the host's own address.
+func validateExtraHost(entry string) error {
+	host, ip, ok := strings.Cut(entry, ":")
+	if !ok {
+		return errors.New("must be of the form HOST:IP")
+	}
+	if err := validateDNSName(host); err != nil {
+		return fmt.Errorf("invalid host: %w", err)
+	}
+	if ip == "host-gateway" {
+		return nil
+	}
+	if net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(ip, "["), "]")) == nil {
+		return fmt.Errorf("%q is not an IP address", ip)
+	}
+	return nil
+}
+
+// validateDNSName checks that name is a dot separated list of RFC 1123 labels.
+func validateDNSName(name string) error {
+	if len(name) > 253 {
+		return errors.New("longer than 253 characters")
+	}
+	for _, label := range strings.Split(name, ".") {
+		if err := validateDNSLabel(label); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateDNSLabel(label string) error {
+	if label == "" {
+		return errors.New("empty label")
+	}
+	if len(label) > 63 {
+		return fmt.Errorf("label %q longer than 63 characters", label)
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return fmt.Errorf("label %q must not start or end with a hyphen", label)
+	}
+	for _, c := range label {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			return fmt.Errorf("label %q contains invalid character %q", label, c)
+		}
+	}
+	return nil
+}
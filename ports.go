@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/docker/go-connections/nat"
+)
+
+// portRow is one container port published on the host.
+type portRow struct {
+	host      *widget.Entry
+	container *widget.Entry
+	proto     *widget.Select
+	row       fyne.CanvasObject
+}
+
+// portList edits the published ports, a row each of host port, container port
+// and protocol.
+type portList struct {
+	rows []*portRow
+	box  *fyne.Container
+}
+
+func newPortList() *portList {
+	return &portList{box: container.NewVBox()}
+}
+
+func (l *portList) widget() fyne.CanvasObject {
+	return container.NewVBox(
+		l.box,
+		container.NewHBox(widget.NewButton("Add", func() { l.add("", "", "tcp") })),
+	)
+}
+
+// add appends a row, it must be called from the UI goroutine.
+func (l *portList) add(host, ctr, proto string) {
+	r := &portRow{
+		host:      widget.NewEntry(),
+		container: widget.NewEntry(),
+		proto:     widget.NewSelect([]string{"tcp", "udp"}, nil),
+	}
+	r.host.SetPlaceHolder("host port, any free one if empty")
+	r.host.SetText(host)
+	r.host.Validator = optional(func(s string) error {
+		_, err := parsePort(s)
+		return err
+	})
+	r.container.SetPlaceHolder("container port")
+	r.container.SetText(ctr)
+	r.container.Validator = r.host.Validator
+	r.proto.SetSelected(proto)
+	remove := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() { l.remove(r) })
+	r.row = container.NewBorder(nil, nil, nil, container.NewHBox(r.proto, remove),
+		container.NewGridWithColumns(2, r.host, r.container))
+	l.rows = append(l.rows, r)
+	l.box.Add(r.row)
+}
+
+func (l *portList) remove(r *portRow) {
+	for i, row := range l.rows {
+		if row == r {
+			l.rows = append(l.rows[:i], l.rows[i+1:]...)
+			break
+		}
+	}
+	l.box.Remove(r.row)
+}
+
+// bindings converts the rows into the ports to expose and what to publish them
+// on, skipping any left blank.
+func (l *portList) bindings() (nat.PortSet, nat.PortMap, error) {
+	var exposed nat.PortSet
+	var bindings nat.PortMap
+	for _, r := range l.rows {
+		host, ctr := strings.TrimSpace(r.host.Text), strings.TrimSpace(r.container.Text)
+		if host == "" && ctr == "" {
+			continue
+		}
+		if ctr == "" {
+			return nil, nil, fmt.Errorf("host port %s needs a container port to publish", host)
+		}
+		if _, err := parsePort(ctr); err != nil {
+			return nil, nil, fmt.Errorf("container port %q: %w", ctr, err)
+		}
+		if host != "" {
+			if _, err := parsePort(host); err != nil {
+				return nil, nil, fmt.Errorf("host port %q: %w", host, err)
+			}
+		}
+		if r.proto.Selected != "tcp" && r.proto.Selected != "udp" {
+			return nil, nil, fmt.Errorf("port %s needs a protocol of tcp or udp", ctr)
+		}
+		port, err := nat.NewPort(r.proto.Selected, ctr)
+		if err != nil {
+			return nil, nil, err
+		}
+		if exposed == nil {
+			exposed, bindings = nat.PortSet{}, nat.PortMap{}
+		}
+		exposed[port] = struct{}{}
+		bindings[port] = append(bindings[port], nat.PortBinding{HostPort: host})
+	}
+	return exposed, bindings, nil
+}
+
+func parsePort(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.New("not a number")
+	}
+	if n < 1 || n > 65535 {
+		return 0, errors.New("must be from 1 to 65535")
+	}
+	return n, nil
+}
+
+// printPorts reports where each published port ended up, as the daemon
+// assigned any left to it.
+func printPorts(w io.Writer, ports nat.PortMap) {
+	keys := make([]nat.Port, 0, len(ports))
+	for p := range ports {
+		keys = append(keys, p)
+	}
+	slices.SortFunc(keys, func(a, b nat.Port) int { return a.Int() - b.Int() })
+	for _, p := range keys {
+		// the daemon binds both IPv4 and IPv6, which are the same to the user
+		seen := map[string]bool{}
+		for _, b := range ports[p] {
+			host := b.HostIP
+			if host == "" || host == "0.0.0.0" || host == "::" {
+				host = "localhost"
+			}
+			addr := net.JoinHostPort(host, b.HostPort)
+			if seen[addr] {
+				continue
+			}
+			seen[addr] = true
+			if p.Proto() == "tcp" {
+				_, _ = fmt.Fprintf(w, "Port %s listening on http://%s\r\n", p, addr)
+			} else {
+				_, _ = fmt.Fprintf(w, "Port %s published on %s\r\n", p, addr)
+			}
+		}
+	}
+}
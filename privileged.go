@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// prefTrustPrivileged is set once the user asks not to be warned before
+// privileged runs any more.
+const prefTrustPrivileged = "security.trustPrivileged"
+
+// confirmPrivileged warns that the container is about to get full access to
+// the host, calling then with whether the user wants to go ahead. It must be
+// called from the UI goroutine.
+func (s *session) confirmPrivileged(opts runOptions, then func(ok bool)) {
+	prefs := s.app.Preferences()
+	if prefs.Bool(prefTrustPrivileged) {
+		then(true)
+		return
+	}
+	image := opts.Image
+	switch {
+	case opts.BuildContext != "":
+		image = "image built from " + opts.BuildContext
+	case image == "":
+		image = defaultImage
+	}
+	msg := widget.NewLabel(fmt.Sprintf("The container, of %s, will run privileged, with all of the\n"+
+		"host's devices and capabilities, which is as good as root on the host.\n\n"+
+		"Only do this for images you trust.", image))
+	dontAsk := widget.NewCheck("Don't ask again", nil)
+	d := dialog.NewCustomConfirm("Run Privileged?", "Run", "Cancel", container.NewVBox(msg, dontAsk),
+		func(ok bool) {
+			if ok && dontAsk.Checked {
+				prefs.SetBool(prefTrustPrivileged, true)
+			}
+			then(ok)
+		}, s.mainWindow)
+	d.Show()
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	prefSaveRuns   = "profiles.saveOnRun"
+	prefProfileDir = "profiles.dir"
+)
+
+// runProfile is everything needed to repeat a run, as written to disk.
+type runProfile struct {
+	Tool    string
+	SavedAt time.Time
+	Image   string
+	Term    string
+	Options runOptions
+	// Note is the user's annotation, added later from the run history.
+	Note string `json:",omitempty"`
+}
+
+func newRunProfile(opts runOptions, now time.Time) runProfile {
+	p := runProfile{
+		Tool:    "fyne-terminal-slow",
+		SavedAt: now,
+		Image:   defaultImage,
+		Term:    containerTerm,
+		Options: opts,
+	}
+	if opts.BuildContext != "" {
+		p.Image = buildTag
+	} else if opts.Image != "" {
+		p.Image = opts.Image
+	}
+	return p
+}
+
+// marshalProfile is the one serialization used for profiles.
+func marshalProfile(p runProfile) ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// defaultProfileDir is where run profiles go unless the user picks somewhere
+// else.
+func defaultProfileDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "fyne-terminal-slow", "runs")
+}
+
+// saveRunProfile writes p to a new timestamped file in dir, returning its path.
+func saveRunProfile(dir string, p runProfile) (string, error) {
+	data, err := marshalProfile(p)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	name := filepath.Join(dir, "run-"+p.SavedAt.Format("20060102-150405.000")+".json")
+	// never overwrite an earlier run's record
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	return name, err
+}
+
+// profileDir is the configured directory for run profiles.
+func profileDir(prefs fyne.Preferences) string {
+	return prefs.StringWithFallback(prefProfileDir, defaultProfileDir())
+}
+
+// recordRun saves the resolved config for a run if the user asked for that,
+// reporting where it went, or why it couldn't be saved, in the terminal.
+func (s *AppState) recordRun(opts runOptions, w io.Writer) {
+	prefs := s.app.Preferences()
+	if !prefs.Bool(prefSaveRuns) {
+		return
+	}
+	name, err := saveRunProfile(profileDir(prefs), newRunProfile(opts, time.Now()))
+	if err != nil {
+		_, _ = fmt.Fprintf(w, "Warning: unable to save run profile: %v\r\n", err)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "Run profile saved to %s\r\n", name)
+}
+
+// showProfileSettings lets the user turn recording runs on or off and choose
+// where the profiles are kept.
+func (s *AppState) showProfileSettings() {
+	prefs := s.app.Preferences()
+	save := widget.NewCheck("Save each run's config as a profile", nil)
+	save.SetChecked(prefs.Bool(prefSaveRuns))
+	dir := widget.NewEntry()
+	dir.SetText(profileDir(prefs))
+	dir.Validator = func(s string) error {
+		if !filepath.IsAbs(s) {
+			return fmt.Errorf("%q is not an absolute path", s)
+		}
+		return nil
+	}
+	d := dialog.NewForm("Run Profiles", "Save", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("", save),
+		widget.NewFormItem("Directory", dir),
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+		prefs.SetBool(prefSaveRuns, save.Checked)
+		if dir.Text == defaultProfileDir() {
+			prefs.RemoveValue(prefProfileDir)
+		} else {
+			prefs.SetString(prefProfileDir, dir.Text)
+		}
+	}, s.mainWindow)
+	d.Resize(fyne.NewSize(500, 0))
+	d.Show()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/go-units"
+)
+
+// pullImage pulls ref, showing each layer's progress on a line of its own in
+// out.
+func pullImage(ctx context.Context, dc dockerClient, ref string, out io.Writer) error {
+	_, _ = fmt.Fprintf(out, "Image %s not found locally, pulling it\r\n", ref)
+	resp, err := dc.ImagePull(ctx, ref, image.PullOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to pull %s: %w", ref, err)
+	}
+	defer resp.Close()
+	if err := showProgress(resp, out); err != nil {
+		return fmt.Errorf("unable to pull %s: %w", ref, err)
+	}
+	return nil
+}
+
+// showProgress renders the daemon's JSON progress messages, from a pull or a
+// build, surfacing an error in them as its own.
+//
+// jsonmessage.DisplayJSONMessagesStream would do this, but it sizes its
+// progress bars from a real TTY's window size, which the terminal widget
+// doesn't have, and at its fallback of 200 columns they wrap and break its
+// cursor movement. Here the lines are kept short instead.
+func showProgress(r io.Reader, out io.Writer) error {
+	dec := json.NewDecoder(r)
+	// the layers being shown, in the order their lines were printed
+	var ids []string
+	for {
+		var jm jsonmessage.JSONMessage
+		if err := dec.Decode(&jm); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if jm.Error != nil {
+			return jm.Error
+		}
+		if jm.Stream != "" {
+			// a build step's own output
+			_, _ = fmt.Fprint(crlfWriter{out}, jm.Stream)
+			// the layers' lines are no longer the last ones to go back up to
+			ids = nil
+			continue
+		}
+		if jm.ID == "" {
+			if jm.Status != "" {
+				_, _ = fmt.Fprintf(out, "%s\r\n", jm.Status)
+				ids = nil
+			}
+			continue
+		}
+		line := jm.ID + ": " + jm.Status
+		if p := jm.Progress; p != nil && p.Total > 0 {
+			line += fmt.Sprintf(" %s/%s (%d%%)",
+				units.HumanSize(float64(p.Current)), units.HumanSize(float64(p.Total)), p.Current*100/p.Total)
+		}
+		idx := -1
+		for i, id := range ids {
+			if id == jm.ID {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			ids = append(ids, jm.ID)
+			_, _ = fmt.Fprintf(out, "%s\r\n", line)
+			continue
+		}
+		// go back up to the layer's line, rewrite it, and come back down
+		up := len(ids) - idx
+		_, _ = fmt.Fprintf(out, "\x1b[%dA\r\x1b[2K%s\r\x1b[%dB", up, line, up)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	dockerContainer "github.com/docker/docker/api/types/container"
+)
+
+// stalledPull is a pull's progress that never comes, until ctx is done, as
+// from a registry that has stopped responding.
+type stalledPull struct {
+	ctx context.Context
+}
+
+func (r stalledPull) Read([]byte) (int, error) {
+	<-r.ctx.Done()
+	return 0, r.ctx.Err()
+}
+
+func TestRunContainerPullCancelled(t *testing.T) {
+	dc := newFakeDocker("", 0)
+	dc.create = func(ctx context.Context, name string) (dockerContainer.CreateResponse, error) {
+		return dockerContainer.CreateResponse{}, cerrdefs.ErrNotFound.WithMessage("no such image: fake")
+	}
+	dc.pull = func(ctx context.Context, ref string) (io.ReadCloser, error) {
+		return io.NopCloser(stalledPull{ctx}), nil
+	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	go func() {
+		waitFor(t, "the pull", func() bool { return dc.called("ImagePull fake") })
+		cancel(errStoppedByUser)
+	}()
+
+	start := time.Now()
+	run := runFake(ctx, t, dc, runOptions{})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %v to give up on the pull", elapsed)
+	}
+	if !errors.Is(run.err, context.Canceled) {
+		t.Errorf("error %v, want it to be context.Canceled", run.err)
+	}
+	if n := dc.count("ContainerCreate"); n != 1 {
+		t.Errorf("ContainerCreate called %d times, want only the once before the pull", n)
+	}
+}
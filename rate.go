@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"github.com/docker/go-units"
+)
+
+// outputMeter counts the output passing through it, as it is written on from
+// the container.
+type outputMeter struct {
+	bytes atomic.Int64
+	lines atomic.Int64
+}
+
+func (m *outputMeter) Write(p []byte) (int, error) {
+	m.bytes.Add(int64(len(p)))
+	m.lines.Add(int64(bytes.Count(p, []byte{'\n'})))
+	return len(p), nil
+}
+
+// watchRate shows how fast output is arriving in the rate label, once a
+// second until ctx is done. With how fast the terminal is, it tells whether
+// slow output is the container's, the daemon's or the terminal's own doing.
+func (s *session) watchRate(ctx context.Context, meter *outputMeter) {
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	defer fyne.Do(func() { s.rateLabel.SetText("") })
+	last := time.Now()
+	var lastBytes, lastLines int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-tick.C:
+			b, l := meter.bytes.Load(), meter.lines.Load()
+			secs := now.Sub(last).Seconds()
+			text := fmt.Sprintf("%s/s, %.0f lines/s",
+				units.HumanSize(float64(b-lastBytes)/secs), float64(l-lastLines)/secs)
+			fyne.Do(func() { s.rateLabel.SetText(text) })
+			last, lastBytes, lastLines = now, b, l
+		}
+	}
+}
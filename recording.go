@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	// recordingMagic starts every recording, each write after it is a frame
+	// of the microseconds since the start, the length and the bytes written.
+	recordingMagic = "fyne-terminal-slow recording 1\n"
+	// maxReplayPause is the longest a replay waits between writes, so a long
+	// wait for input while recording doesn't hold it up.
+	maxReplayPause = 5 * time.Second
+	// maxRecordingFrame guards against a corrupt recording asking for a huge
+	// write.
+	maxRecordingFrame = 64 << 20
+)
+
+// recording is the current run's output with when each piece of it was
+// written, kept in a temporary file like the transcript, for replaying later.
+type recording struct {
+	mu    sync.Mutex
+	file  transcript
+	start time.Time
+	frame []byte
+}
+
+// reset discards the previous run's recording and starts anew.
+func (r *recording) reset() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.file.reset(); err != nil {
+		return err
+	}
+	r.start = time.Now()
+	_, _ = r.file.Write([]byte(recordingMagic))
+	return nil
+}
+
+// Write never fails, as with the transcript.
+func (r *recording) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.frame = binary.AppendUvarint(r.frame[:0], uint64(time.Since(r.start).Microseconds()))
+	r.frame = binary.AppendUvarint(r.frame, uint64(len(p)))
+	r.frame = append(r.frame, p...)
+	_, _ = r.file.Write(r.frame)
+	return len(p), nil
+}
+
+// save copies the recording so far to w.
+func (r *recording) save(w io.Writer) error {
+	return r.file.save(w, false)
+}
+
+// close removes the recording, as the app exits.
+func (r *recording) close() {
+	r.file.close()
+}
+
+// replayRecording writes the recording at path to w, speed times as fast as it
+// was recorded or as fast as it can be for 0, until it ends or ctx is done.
+func replayRecording(ctx context.Context, path string, speed float64, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(recordingMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != recordingMagic {
+		return fmt.Errorf("%s is not a recording", path)
+	}
+	var last uint64
+	var data []byte
+	for {
+		at, err := binary.ReadUvarint(r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("corrupt recording: %w", err)
+		}
+		n, err := binary.ReadUvarint(r)
+		if err == nil && n > maxRecordingFrame {
+			err = errors.New("frame too large")
+		}
+		if err == nil {
+			if uint64(cap(data)) < n {
+				data = make([]byte, n)
+			}
+			data = data[:n]
+			_, err = io.ReadFull(r, data)
+		}
+		if err != nil {
+			// the end of a recording still being written when it was saved
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return fmt.Errorf("corrupt recording: %w", err)
+		}
+		if speed > 0 && at > last {
+			pause := min(time.Duration(float64(at-last)/speed)*time.Microsecond, maxReplayPause)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(pause):
+			}
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		last = max(last, at)
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+}
+
+// replayRun is reallyRun for a replay, with the terminal etc all set up.
+func (s *session) replayRun(ctx context.Context, opts runOptions, stdout io.Writer) {
+	s.waiting.hide()
+	fyne.Do(func() { s.setRunTitle("Replay of " + filepath.Base(opts.Replay)) })
+	defer fyne.Do(func() { s.setRunTitle("") })
+	s.setStatus("Replaying "+opts.Replay, false)
+	err := replayRecording(ctx, opts.Replay, opts.ReplaySpeed, stdout)
+	switch {
+	case ctx.Err() != nil:
+		_, _ = fmt.Fprintf(stdout, "\r\nReplay %v\r\n", stopReason(ctx))
+		s.setStatus("Stopped", false)
+	case err != nil:
+		s.setStatus("Error: "+err.Error(), true)
+		fyne.Do(func() {
+			dialog.NewError(fmt.Errorf("replay failed: %w", err), s.mainWindow).Show()
+		})
+	default:
+		s.setStatus("Replay finished", false)
+	}
+}
+
+// replaySpeeds are the choices offered for a replay, 0 being as fast as it
+// can go.
+var replaySpeeds = []struct {
+	label string
+	speed float64
+}{
+	{"As recorded", 1},
+	{"2× as fast", 2},
+	{"5× as fast", 5},
+	{"10× as fast", 10},
+	{"As fast as possible", 0},
+}
+
+// saveRecording saves the last run's output, with its timing, for replaying.
+func (s *session) saveRecording() {
+	d := dialog.NewFileSave(func(w fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, s.mainWindow)
+			return
+		}
+		if w == nil {
+			return // cancelled
+		}
+		// it may be large, keep the copy off the UI goroutine
+		go func() {
+			err := s.recording.save(w)
+			if cErr := w.Close(); err == nil {
+				err = cErr
+			}
+			if err != nil {
+				fyne.Do(func() {
+					dialog.ShowError(fmt.Errorf("unable to save recording: %w", err), s.mainWindow)
+				})
+			}
+		}()
+	}, s.mainWindow)
+	d.SetFileName("output.rec")
+	d.SetFilter(storage.NewExtensionFileFilter([]string{".rec"}))
+	d.Show()
+}
+
+// replay plays a saved recording back into the terminal, as a run of its own,
+// at the speed chosen.
+func (s *session) replay() {
+	d := dialog.NewFileOpen(func(r fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, s.mainWindow)
+			return
+		}
+		if r == nil {
+			return // cancelled
+		}
+		path, name := r.URI().Path(), r.URI().Name()
+		_ = r.Close()
+		labels := make([]string, len(replaySpeeds))
+		for i, sp := range replaySpeeds {
+			labels[i] = sp.label
+		}
+		speed := widget.NewSelect(labels, nil)
+		speed.SetSelectedIndex(0)
+		dialog.ShowCustomConfirm("Replay "+name, "Replay", "Cancel", speed, func(ok bool) {
+			if ok {
+				s.start(runOptions{Replay: path, ReplaySpeed: replaySpeeds[speed.SelectedIndex()].speed})
+			}
+		}, s.mainWindow)
+	}, s.mainWindow)
+	d.SetFilter(storage.NewExtensionFileFilter([]string{".rec"}))
+	d.Show()
+}
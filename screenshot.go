@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+)
+
+// captureObject renders just the region of the window's canvas covered by obj.
+//
+// The canvas is captured at its full pixel density, so on HiDPI displays the
+// image has more pixels than obj has Fyne units and stays crisp.
+func captureObject(c fyne.Canvas, obj fyne.CanvasObject) (image.Image, error) {
+	full := c.Capture()
+	if full == nil || c.Size().Width == 0 {
+		return nil, errors.New("window has not been drawn yet")
+	}
+	scale := float64(full.Bounds().Dx()) / float64(c.Size().Width)
+
+	pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(obj)
+	size := obj.Size()
+	px := func(f float32) int { return int(math.Round(float64(f) * scale)) }
+	region := image.Rect(px(pos.X), px(pos.Y), px(pos.X+size.Width), px(pos.Y+size.Height)).
+		Add(full.Bounds().Min).
+		Intersect(full.Bounds())
+	if region.Empty() {
+		return nil, errors.New("terminal is not visible")
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+	draw.Draw(img, img.Bounds(), full, region.Min, draw.Src)
+	return img, nil
+}
+
+// saveScreenshot captures the terminal area and asks where to save it as a
+// PNG.
+func (s *session) saveScreenshot() {
+	img, err := captureObject(s.mainWindow.Canvas(), s.termArea)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("unable to capture terminal: %w", err), s.mainWindow)
+		return
+	}
+	d := dialog.NewFileSave(func(w fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, s.mainWindow)
+			return
+		}
+		if w == nil {
+			return // cancelled
+		}
+		err = png.Encode(w, img)
+		if cErr := w.Close(); err == nil {
+			err = cErr
+		}
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("unable to save screenshot: %w", err), s.mainWindow)
+		}
+	}, s.mainWindow)
+	d.SetFileName("terminal.png")
+	d.SetFilter(storage.NewExtensionFileFilter([]string{".png"}))
+	d.Show()
+}
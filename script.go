@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sequenceScript builds a /bin/sh script that runs each of the commands in
+// turn, printing a separator before each one and its exit status after it.
+//
+// If stopOnFailure is set the script stops at the first command that fails and
+// exits with its status, otherwise every command is run and the script exits
+// with the status of the last one that failed.
+func sequenceScript(commands []string, stopOnFailure bool) string {
+	var sb strings.Builder
+	sb.WriteString("status=0\n")
+	for i, cmd := range commands {
+		label := shellQuote(fmt.Sprintf("[%d/%d] %s", i+1, len(commands), cmd))
+		fmt.Fprintf(&sb, "printf '\\n=== %%s ===\\n' %s\n", label)
+		fmt.Fprintf(&sb, "%s\n", cmd)
+		sb.WriteString("rc=$?\n")
+		fmt.Fprintf(&sb, "printf '=== %%s: exit status %%d ===\\n' %s \"$rc\"\n", label)
+		if stopOnFailure {
+			sb.WriteString("[ \"$rc\" -eq 0 ] || exit \"$rc\"\n")
+		} else {
+			sb.WriteString("[ \"$rc\" -eq 0 ] || status=$rc\n")
+		}
+	}
+	sb.WriteString("exit \"$status\"\n")
+	return sb.String()
+}
+
+// shellQuote quotes s so that /bin/sh treats it as a single literal word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellSplit splits a command line into arguments the way /bin/sh would,
+// honouring single and double quotes and backslash escapes, but without any
+// expansion.
+func shellSplit(line string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			inWord = true
+			if i+1 < len(line) {
+				i++
+				if line[i] != '\n' {
+					word.WriteByte(line[i])
+				}
+			}
+		case c == '\'':
+			inWord = true
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote in %q", line)
+			}
+			word.WriteString(line[i+1 : i+1+end])
+			i += end + 1
+		case c == '"':
+			inWord = true
+			closed := false
+			for i++; i < len(line); i++ {
+				if line[i] == '"' {
+					closed = true
+					break
+				}
+				// inside double quotes a backslash only escapes these
+				if line[i] == '\\' && i+1 < len(line) && strings.IndexByte("\"\\$`\n", line[i+1]) >= 0 {
+					i++
+					if line[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(line[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated double quote in %q", line)
+			}
+		default:
+			inWord = true
+			word.WriteByte(c)
+		}
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
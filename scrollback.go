@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	prefScrollbackLines = "output.scrollbackLines"
+
+	// defaultScrollbackLines is how many lines are kept unless the user says
+	// otherwise, and minScrollbackLines the fewest they may ask for.
+	defaultScrollbackLines = 10000
+	minScrollbackLines     = 100
+	scrollbackRefresh      = 200 * time.Millisecond
+)
+
+// scrollback keeps a bounded, plain text copy of the session output.
+//
+// The terminal widget only holds what is on screen, so this is the only record
+// of output that has scrolled off the top. Escape sequences are stripped as it
+// is written, other than CSI 3 J (erase saved lines) which clears it just as it
+// would clear a real terminal's history, and OSC 133 prompt marks which are
+// noted so that the view can jump between commands.
+type scrollback struct {
+	mu        sync.Mutex
+	max       int
+	lines     []string
+	dropped   int // lines trimmed from the front, ever
+	changed   bool
+	partial   []byte
+	scanner   ansiScanner
+	pendingCR bool
+
+	// prompts are the absolute numbers (counting dropped lines) of the lines
+	// where a shell prompt starts, in order
+	prompts       []int
+	promptPattern *regexp.Regexp
+}
+
+func newScrollback() *scrollback {
+	return &scrollback{max: defaultScrollbackLines}
+}
+
+func (b *scrollback) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.scanner.scan(p, b.writeToken)
+	b.changed = true
+	return len(p), nil
+}
+
+// setMaxLines sets how many lines are kept, dropping the oldest if there are
+// already more.
+func (b *scrollback) setMaxLines(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.max = n
+	b.trim()
+}
+
+// setPromptPattern sets the regular expression that marks lines as prompts,
+// for shells that don't emit OSC 133 marks, or nil for none.
+func (b *scrollback) setPromptPattern(re *regexp.Regexp) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.promptPattern = re
+}
+
+func (b *scrollback) writeToken(kind tokenKind, tok []byte) {
+	if kind == tokenEscape {
+		switch {
+		case string(tok) == "\x1b[3J":
+			b.clear()
+		case bytes.HasPrefix(tok, []byte("\x1b]133;A")):
+			// OSC 133 semantic prompt mark, the prompt starts on the current line
+			b.markPrompt(b.dropped + len(b.lines))
+		}
+		return
+	}
+	if b.pendingCR {
+		b.pendingCR = false
+		if kind != tokenControl || tok[0] != '\n' {
+			// a bare carriage return, e.g. a progress bar, overwrites the line
+			b.partial = b.partial[:0]
+		}
+	}
+	if kind == tokenText {
+		b.partial = append(b.partial, tok...)
+		return
+	}
+	switch tok[0] {
+	case '\r':
+		b.pendingCR = true
+	case '\n':
+		b.endLine()
+	case '\b':
+		if len(b.partial) > 0 {
+			_, size := utf8.DecodeLastRune(b.partial)
+			b.partial = b.partial[:len(b.partial)-size]
+		}
+	case '\t':
+		b.partial = append(b.partial, '\t')
+	}
+}
+
+func (b *scrollback) markPrompt(line int) {
+	if n := len(b.prompts); n == 0 || b.prompts[n-1] < line {
+		b.prompts = append(b.prompts, line)
+	}
+}
+
+func (b *scrollback) endLine() {
+	line := string(b.partial)
+	if b.promptPattern != nil && b.promptPattern.MatchString(line) {
+		b.markPrompt(b.dropped + len(b.lines))
+	}
+	b.lines = append(b.lines, line)
+	b.partial = b.partial[:0]
+	b.trim()
+}
+
+func (b *scrollback) trim() {
+	if over := len(b.lines) - b.max; over > 0 {
+		b.dropped += over
+		b.lines = b.lines[over:]
+		b.trimPrompts()
+		b.changed = true
+		// don't let the trimmed front of the array pin memory forever
+		if cap(b.lines) > 2*b.max {
+			b.lines = append([]string(nil), b.lines...)
+		}
+	}
+}
+
+// trimPrompts forgets the prompts on lines that have been dropped.
+func (b *scrollback) trimPrompts() {
+	i := sort.SearchInts(b.prompts, b.dropped)
+	b.prompts = b.prompts[i:]
+}
+
+func (b *scrollback) clear() {
+	b.dropped += len(b.lines)
+	b.lines = nil
+	b.partial = b.partial[:0]
+	b.prompts = nil
+}
+
+// promptLines returns the absolute line numbers of the prompts still in the
+// buffer.
+func (b *scrollback) promptLines() []int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]int(nil), b.prompts...)
+}
+
+// snapshot returns the complete lines so far, plus how many have ever been
+// trimmed from the front. The lines are never modified in place so the caller
+// may keep the slice.
+func (b *scrollback) snapshot() (lines []string, dropped int, changed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	changed, b.changed = b.changed, false
+	return b.lines[:len(b.lines):len(b.lines)], b.dropped, changed
+}
+
+// scrollbackLines is the configured number of lines to keep.
+func scrollbackLines(prefs fyne.Preferences) int {
+	return max(prefs.IntWithFallback(prefScrollbackLines, defaultScrollbackLines), minScrollbackLines)
+}
+
+// scrollbackView shows the scrollback in a list that follows new output as
+// long as it is scrolled to the bottom. Once the user scrolls up it stays where
+// it is, until they scroll back down to the bottom, like tmux or iTerm.
+type scrollbackView struct {
+	buf     *scrollback
+	list    *widget.List
+	status  *widget.Label
+	lines   []string
+	dropped int
+	widget  fyne.CanvasObject
+
+	// search finds text in the lines, current being the line of the match
+	// selected, or -1
+	search    *widget.Entry
+	searchBar fyne.CanvasObject
+	matches   *widget.Label
+	current   int
+}
+
+func newScrollbackView(buf *scrollback) *scrollbackView {
+	v := &scrollbackView{buf: buf, status: widget.NewLabel(""), current: -1}
+	v.list = widget.NewList(
+		func() int { return len(v.lines) },
+		func() fyne.CanvasObject {
+			l := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Monospace: true})
+			l.Truncation = fyne.TextTruncateClip
+			return l
+		},
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(v.lines[id])
+		},
+	)
+	v.status.TextStyle.Italic = true
+	v.search = widget.NewEntry()
+	v.search.SetPlaceHolder("Find in scrollback")
+	// incremental, from the match already found if it still matches
+	v.search.OnChanged = func(string) { v.find(0) }
+	v.search.OnSubmitted = func(string) { v.find(1) }
+	v.matches = widget.NewLabel("")
+	v.searchBar = container.NewBorder(nil, nil, nil, container.NewHBox(
+		v.matches,
+		widget.NewButtonWithIcon("", theme.MoveUpIcon(), func() { v.find(-1) }),
+		widget.NewButtonWithIcon("", theme.MoveDownIcon(), func() { v.find(1) }),
+		widget.NewButtonWithIcon("", theme.CancelIcon(), v.hideSearch),
+	), v.search)
+	v.searchBar.Hide()
+	v.widget = container.NewBorder(container.NewVBox(v.status, v.searchBar), nil, nil, nil, v.list)
+	v.setFollowing(true)
+	return v
+}
+
+// run polls the buffer for changes, so that bursts of output cost one refresh
+// per scrollbackRefresh no matter how many writes they arrive in.
+func (v *scrollbackView) run(ctx context.Context) {
+	t := time.NewTicker(scrollbackRefresh)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			lines, dropped, changed := v.buf.snapshot()
+			if changed {
+				fyne.Do(func() { v.update(lines, dropped) })
+			}
+		}
+	}
+}
+
+func (v *scrollbackView) rowHeight() float32 {
+	return v.list.CreateItem().MinSize().Height + theme.Padding()
+}
+
+// atBottom reports whether the list is scrolled to (within half a row of) the
+// end of what it is currently showing.
+func (v *scrollbackView) atBottom() bool {
+	row := v.rowHeight()
+	maxOffset := row*float32(len(v.lines)) - theme.Padding() - v.list.Size().Height
+	return v.list.GetScrollOffset() >= maxOffset-row/2
+}
+
+func (v *scrollbackView) update(lines []string, dropped int) {
+	follow := v.atBottom()
+	trimmed := dropped - v.dropped
+	offset := v.list.GetScrollOffset()
+	v.lines, v.dropped = lines, dropped
+	if v.current >= 0 {
+		// the match stays selected as the lines before it are trimmed
+		if v.current -= trimmed; v.current >= 0 {
+			v.list.Select(v.current)
+		} else {
+			v.list.UnselectAll()
+		}
+	}
+	v.list.Refresh()
+	if follow {
+		v.list.ScrollToBottom()
+	} else if trimmed > 0 {
+		// keep the lines being read in place as the front is trimmed
+		v.list.ScrollToOffset(offset - float32(trimmed)*v.rowHeight())
+	}
+	v.setFollowing(follow)
+}
+
+// jumpPrompt scrolls the list so that the next prompt after (or before, if
+// dir is negative) the top line is at the top.
+func (v *scrollbackView) jumpPrompt(dir int) {
+	row := v.rowHeight()
+	top := int(v.list.GetScrollOffset()/row + 0.5)
+	target := -1
+	for _, abs := range v.buf.promptLines() {
+		line := abs - v.dropped
+		if line < 0 || line >= len(v.lines) {
+			continue
+		}
+		if dir > 0 && line > top {
+			target = line
+			break
+		}
+		if dir < 0 && line < top {
+			target = line
+		}
+	}
+	if target < 0 {
+		v.status.SetText("No more prompts, they are found by OSC 133 marks or the prompt pattern")
+		return
+	}
+	v.list.ScrollToOffset(float32(target) * row)
+	v.setFollowing(v.atBottom())
+}
+
+func (v *scrollbackView) setFollowing(follow bool) {
+	if follow {
+		v.status.SetText("Following output")
+	} else {
+		v.status.SetText("Paused, scroll to the bottom to follow output")
+	}
+}
+
+// showSearch opens the search bar, it is up to the caller to focus it.
+func (v *scrollbackView) showSearch() {
+	v.searchBar.Show()
+}
+
+func (v *scrollbackView) hideSearch() {
+	v.searchBar.Hide()
+	v.current = -1
+	v.list.UnselectAll()
+}
+
+// find selects and scrolls to the next line (or previous, if dir is negative)
+// containing the search text, ignoring case and wrapping around at either end.
+// A dir of zero looks from the current match itself, as the text is typed.
+func (v *scrollbackView) find(dir int) {
+	query := strings.ToLower(v.search.Text)
+	if query == "" {
+		v.current = -1
+		v.list.UnselectAll()
+		v.matches.SetText("")
+		return
+	}
+	var found []int
+	for i, l := range v.lines {
+		if strings.Contains(strings.ToLower(l), query) {
+			found = append(found, i)
+		}
+	}
+	if len(found) == 0 {
+		v.current = -1
+		v.list.UnselectAll()
+		v.matches.SetText("No matches")
+		return
+	}
+	from := v.current
+	if from < 0 {
+		// start from what is on screen, taking the top line as the next
+		from = int(v.list.GetScrollOffset()/v.rowHeight() + 0.5)
+		dir = min(dir, 0)
+	}
+	// the index in found of the first match at or after from
+	next := sort.SearchInts(found, from)
+	var target int
+	switch {
+	case dir < 0:
+		target = (next - 1 + len(found)) % len(found)
+	case dir > 0 && next < len(found) && found[next] == from:
+		target = (next + 1) % len(found)
+	default:
+		target = next % len(found)
+	}
+	v.current = found[target]
+	v.list.Select(v.current)
+	v.list.ScrollTo(v.current)
+	v.matches.SetText(fmt.Sprintf("%d of %d", target+1, len(found)))
+	v.setFollowing(v.atBottom())
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/fyne-io/terminal"
+)
+
+// session is a tab with its own terminal, and the run going on in it. Each
+// session's runs are independent of every other's, so that several containers
+// can run side by side. The options form, and the app's settings, are shared.
+type session struct {
+	*AppState
+	// ctx is done once the session is closed, which stops its run
+	ctx    context.Context
+	cancel context.CancelFunc
+	tab    *container.TabItem
+	name   string
+
+	terminal *terminal.Terminal
+	termSize *termSizeTracker
+	spinner  *spinner
+
+	// stderrTerminal is only shown, stacked below terminal in termArea, for runs
+	// that split the output streams.
+	stderrTerminal *terminal.Terminal
+	termArea       *fyne.Container
+	// termFont sets the text size of everything in termArea
+	termFont *container.ThemeOverride
+
+	scrollback      *scrollback
+	scrollbackView  *scrollbackView
+	scrollbackCheck *widget.Check
+	center          *fyne.Container
+
+	// output feeds the terminal during a run, it is nil while idle
+	outputMu sync.Mutex
+	output   io.Writer
+
+	// active is the running container, nil while idle
+	activeMu     sync.Mutex
+	active       *activeContainer
+	limitsButton *widget.Button
+	idleLabel    *widget.Label
+
+	// containerID is the ID of the run's container, which is kept after the
+	// run if the container is, only used from the UI goroutine
+	containerID  string
+	copyIDButton *widget.Button
+
+	// running is set for as long as a run is in progress, only one can use
+	// the terminal at a time
+	running   atomic.Bool
+	runButton *widget.Button
+	// termBroken is set once a terminal has failed, and nothing more can be
+	// run
+	termBroken atomic.Bool
+	// daemonErr is why the daemon couldn't be reached when last checked, nil
+	// if it could be or the check is still going, and stopCheck gives up on
+	// the check, both only used from the UI goroutine
+	daemonErr error
+	stopCheck context.CancelFunc
+
+	// lastRun is what was last run, to repeat it, it is only used from the
+	// UI goroutine
+	lastRun        *runOptions
+	runAgainButton *widget.Button
+
+	// stopRun cancels the current run, it is nil while idle
+	stopMu     sync.Mutex
+	stopRun    context.CancelCauseFunc
+	stopButton *widget.Button
+
+	// background holds the terminal output back while it can't be seen, see
+	// pauseUnseen
+	background pauseGroup
+
+	// viewers gets a copy of the output for browsers, if serving them
+	viewers *viewerHub
+	// exitWhenDone, if set, is told how the next run ended so the app can
+	// quit with its exit code
+	exitWhenDone func(exitCode int, err error)
+
+	// runTitle describes the running container and termTitle is the title it
+	// set, both shown in the tab and, while selected, the window title, they
+	// are only used from the UI goroutine
+	runTitle  string
+	termTitle string
+	bell      *bellOverlay
+
+	// statusBar shows what the current, or last, run is doing, and rateLabel
+	// how fast its output is arriving
+	statusBar *widget.Label
+	rateLabel *widget.Label
+	// waiting covers the terminal until the container first writes something
+	waiting *waitOverlay
+
+	// transcript is the raw output of the current (or last) run, and
+	// recording the same with its timing
+	transcript transcript
+	recording  recording
+}
+
+// newSession creates a session, for a new tab, and checks the daemon can be
+// reached before it is run in. It must be called from the UI goroutine.
+func (s *AppState) newSession() *session {
+	s.nextSession++
+	ctx, cancel := context.WithCancel(s.ctx)
+	sess := &session{
+		AppState: s,
+		ctx:      ctx,
+		cancel:   cancel,
+		name:     fmt.Sprintf("Session %d", s.nextSession),
+	}
+	sess.terminal = terminal.New()
+	sess.termSize = newTermSizeTracker(sess.terminal)
+	sess.watchTitle(sess.terminal)
+	sess.stderrTerminal = terminal.New()
+	sess.termArea = container.NewStack(sess.terminal)
+	sess.termFont = s.newTermFont(sess.termArea)
+	sess.center = container.NewStack(sess.termFont)
+	sess.scrollback = newScrollback()
+	sess.scrollbackView = newScrollbackView(sess.scrollback)
+	go sess.scrollbackView.run(ctx)
+	sess.spinner = newSpinner()
+	sess.bell = newBellOverlay()
+	sess.statusBar = newStatusBar()
+	sess.rateLabel = widget.NewLabel("")
+	sess.waiting = newWaitOverlay()
+	sess.scrollbackCheck = widget.NewCheck("Scrollback", sess.showScrollback)
+	sess.limitsButton = widget.NewButton("Limits…", sess.showUpdateLimits)
+	sess.limitsButton.Disable()
+	sess.copyIDButton = widget.NewButtonWithIcon("Copy ID", theme.ContentCopyIcon(), sess.copyContainerID)
+	sess.copyIDButton.Disable()
+	sess.runButton = widget.NewButtonWithIcon("Run!", theme.DownloadIcon(), sess.run)
+	sess.runAgainButton = widget.NewButtonWithIcon("Run Again", theme.MediaReplayIcon(), sess.runAgain)
+	sess.runAgainButton.Disable()
+	sess.stopButton = widget.NewButtonWithIcon("Stop", theme.MediaStopIcon(), sess.stop)
+	sess.stopButton.Disable()
+	sess.idleLabel = widget.NewLabel("")
+	sess.idleLabel.Importance = widget.WarningImportance
+
+	content := container.NewBorder(
+		// top
+		container.NewBorder(
+			nil, nil, nil,
+			container.NewHBox(
+				sess.idleLabel,
+				sess.scrollbackCheck,
+				sess.limitsButton,
+				sess.copyIDButton,
+				sess.spinner,
+			),
+			container.NewHBox(
+				sess.runButton,
+				sess.runAgainButton,
+				sess.stopButton,
+				widget.NewButtonWithIcon("Clear", theme.ContentClearIcon(), sess.clearTerminal),
+			),
+		),
+		container.NewBorder(nil, nil, nil, sess.rateLabel, sess.statusBar), // bottom
+		nil, // left
+		nil, // right
+		// center
+		container.NewStack(sess.center, sess.waiting.box, sess.bell.rect),
+	)
+	sess.tab = container.NewTabItem(sess.name, content)
+
+	s.keymap.addRegistries(&sess.terminal.ShortcutHandler, &sess.stderrTerminal.ShortcutHandler)
+	s.sessions = append(s.sessions, sess)
+	sess.recheckDaemon()
+	return sess
+}
+
+// addSession opens a new session in a tab of its own, and selects it.
+func (s *AppState) addSession() {
+	sess := s.newSession()
+	s.tabs.Append(sess.tab)
+	s.tabs.Select(sess.tab)
+}
+
+// closeSession stops the session's run, if any, and removes its tab. Closing
+// the last one leaves a new, empty, session in its place.
+func (s *AppState) closeSession(sess *session) {
+	if sess == nil {
+		return
+	}
+	sess.stop()
+	sess.cancel()
+	// the run, now stopping, only writes to the transcript if it is open
+	sess.transcript.close()
+	sess.recording.close()
+	s.keymap.removeRegistries(&sess.terminal.ShortcutHandler, &sess.stderrTerminal.ShortcutHandler)
+	s.sessions = slices.DeleteFunc(s.sessions, func(other *session) bool { return other == sess })
+	s.tabs.Remove(sess.tab)
+	if len(s.sessions) == 0 {
+		s.addSession()
+	}
+	s.pauseUnseen()
+}
+
+// setContainerID records the run's container, or "" once there isn't one, and
+// enables copying its ID to match. It must be called from the UI goroutine.
+func (s *session) setContainerID(id string) {
+	s.containerID = id
+	if id != "" {
+		s.copyIDButton.Enable()
+	} else {
+		s.copyIDButton.Disable()
+	}
+}
+
+// copyContainerID puts the ID of the run's container on the clipboard, e.g.
+// for docker logs.
+func (s *session) copyContainerID() {
+	if s.containerID != "" {
+		s.app.Clipboard().SetContent(s.containerID)
+	}
+}
+
+// sessionFor finds the session shown in a tab, nil if it has been closed.
+func (s *AppState) sessionFor(item *container.TabItem) *session {
+	for _, sess := range s.sessions {
+		if sess.tab == item {
+			return sess
+		}
+	}
+	return nil
+}
+
+// current is the session in the selected tab, there is always one.
+func (s *AppState) current() *session {
+	if sess := s.sessionFor(s.tabs.Selected()); sess != nil {
+		return sess
+	}
+	return s.sessions[0]
+}
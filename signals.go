@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"golang.org/x/sys/unix"
+)
+
+// menuSignals are the signals the Send Signal menu offers, the app itself
+// never receives them to forward as the terminal is a widget.
+var menuSignals = []unix.Signal{
+	unix.SIGINT, unix.SIGTERM, unix.SIGHUP, unix.SIGKILL, unix.SIGUSR1, unix.SIGUSR2,
+}
+
+// signalMenu builds the Send Signal submenu, acting on the current session.
+func (s *AppState) signalMenu() *fyne.MenuItem {
+	items := make([]*fyne.MenuItem, len(menuSignals))
+	for i, sig := range menuSignals {
+		items[i] = fyne.NewMenuItem(unix.SignalName(sig), func() { s.current().sendSignal(sig) })
+	}
+	m := fyne.NewMenuItem("Send Signal", nil)
+	m.ChildMenu = fyne.NewMenu("", items...)
+	return m
+}
+
+// sendSignal sends sig to the running container, as with docker kill. When
+// attached to an exec'd command it still goes to the container's main
+// process, the API can't signal the command itself.
+func (s *session) sendSignal(sig unix.Signal) {
+	c := s.currentContainer()
+	if c == nil {
+		dialog.ShowInformation("Send Signal", "There is no container running to signal.", s.mainWindow)
+		return
+	}
+	name := unix.SignalName(sig)
+	go func() {
+		log := lifecycleLog.With("container", shortID(c.id))
+		err := retryTransient(s.ctx, defaultResizeBackoff, func() error {
+			return c.dc.ContainerKill(s.ctx, c.id, name)
+		})
+		if err != nil {
+			log.Error("container signal failed", "signal", name, "err", err)
+			fyne.Do(func() {
+				dialog.ShowError(fmt.Errorf("unable to send %s: %w", name, err), s.mainWindow)
+			})
+			return
+		}
+		log.Info("container signalled", "signal", name)
+	}()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	spinnerDots   = 8
+	spinnerPeriod = time.Second
+)
+
+// spinner is a small activity indicator.
+//
+// It is driven by a fyne.Animation so that it ticks on the render loop instead
+// of a goroutine of its own, and it only refreshes when the highlighted dot
+// changes. That caps it at spinnerDots refreshes per spinnerPeriod no matter
+// the frame rate, so it doesn't compete with the terminal for the CPU while
+// there is a lot of output to draw.
+type spinner struct {
+	widget.BaseWidget
+
+	anim  *fyne.Animation
+	frame int
+	dots  [spinnerDots]*canvas.Circle
+}
+
+func newSpinner() *spinner {
+	s := &spinner{}
+	for i := range s.dots {
+		s.dots[i] = canvas.NewCircle(theme.Color(theme.ColorNameDisabled))
+	}
+	s.anim = fyne.NewAnimation(spinnerPeriod, s.tick)
+	s.anim.Curve = fyne.AnimationLinear
+	s.anim.RepeatCount = fyne.AnimationRepeatForever
+	s.ExtendBaseWidget(s)
+	s.Hide()
+	return s
+}
+
+// Start shows the spinner and starts animating it, it must be called from the
+// UI goroutine.
+func (s *spinner) Start() {
+	s.Show()
+	s.anim.Start()
+}
+
+// Stop stops the animation and hides the spinner, it must be called from the
+// UI goroutine.
+func (s *spinner) Stop() {
+	s.anim.Stop()
+	s.Hide()
+}
+
+func (s *spinner) tick(progress float32) {
+	frame := int(progress*spinnerDots) % spinnerDots
+	if frame == s.frame {
+		return
+	}
+	s.dots[s.frame].FillColor = theme.Color(theme.ColorNameDisabled)
+	s.dots[frame].FillColor = theme.Color(theme.ColorNamePrimary)
+	s.dots[s.frame].Refresh()
+	s.dots[frame].Refresh()
+	s.frame = frame
+}
+
+func (s *spinner) MinSize() fyne.Size {
+	size := theme.IconInlineSize()
+	return fyne.NewSize(size, size)
+}
+
+func (s *spinner) CreateRenderer() fyne.WidgetRenderer {
+	return &spinnerRenderer{s: s}
+}
+
+type spinnerRenderer struct {
+	s *spinner
+}
+
+func (r *spinnerRenderer) Layout(size fyne.Size) {
+	side := fyne.Min(size.Width, size.Height)
+	dot := side / 5
+	radius := (side - dot) / 2
+	center := fyne.NewPos(size.Width/2, size.Height/2)
+	for i, c := range r.s.dots {
+		angle := 2 * math.Pi * float64(i) / spinnerDots
+		c.Resize(fyne.NewSquareSize(dot))
+		c.Move(fyne.NewPos(
+			center.X+radius*float32(math.Sin(angle))-dot/2,
+			center.Y-radius*float32(math.Cos(angle))-dot/2,
+		))
+	}
+}
+
+func (r *spinnerRenderer) MinSize() fyne.Size {
+	return r.s.MinSize()
+}
+
+func (r *spinnerRenderer) Refresh() {
+	for i, c := range r.s.dots {
+		if i == r.s.frame {
+			c.FillColor = theme.Color(theme.ColorNamePrimary)
+		} else {
+			c.FillColor = theme.Color(theme.ColorNameDisabled)
+		}
+		c.Refresh()
+	}
+}
+
+func (r *spinnerRenderer) Objects() []fyne.CanvasObject {
+	objs := make([]fyne.CanvasObject, len(r.s.dots))
+	for i, c := range r.s.dots {
+		objs[i] = c
+	}
+	return objs
+}
+
+func (r *spinnerRenderer) Destroy() {}
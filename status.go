@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// runHooks are how a run tells the UI about its progress, and asks it what to
+// do, they may be called from any goroutine.
+type runHooks struct {
+	// created is called once the container exists
+	created func(id, image string)
+	// status describes what the run is doing
+	status func(text string, failed bool)
+	// output is called as the container first writes something
+	output func()
+	// finished describes how the container exited, failed if it went wrong
+	finished func(text string, failed bool)
+	// exited is given the container's, or exec'd command's, exit code once
+	// there is one
+	exited func(code int)
+	// nameInUse is called, blocking the run, when another container has the
+	// name it was to be given
+	nameInUse func(name string) nameConflict
+}
+
+// newStatusBar is the label along the bottom of the window showing what the
+// current, or last, run is doing.
+func newStatusBar() *widget.Label {
+	l := widget.NewLabel("Idle")
+	l.Truncation = fyne.TextTruncateEllipsis
+	return l
+}
+
+// setStatus shows text in the status bar, in red if failed.
+func (s *session) setStatus(text string, failed bool) {
+	fyne.Do(func() {
+		s.statusBar.Importance = widget.MediumImportance
+		if failed {
+			s.statusBar.Importance = widget.DangerImportance
+		}
+		s.statusBar.SetText(text)
+	})
+}
+
+// daemonRetry is how often the daemon is tried again while it can't be
+// reached at startup.
+const daemonRetry = 5 * time.Second
+
+// checkDaemon makes sure the docker daemon can be reached before a run, so
+// that it not running is clear up front rather than from a failed run. Run is
+// disabled until it can be, which it is tried for again every daemonRetry.
+func (s *session) checkDaemon(ctx context.Context) {
+	fyne.Do(s.runButton.Disable)
+	for {
+		var daemon dockerDaemon
+		fyne.DoAndWait(func() { daemon = s.options.daemon() })
+		version, err := daemonVersion(ctx, daemon)
+		fyne.DoAndWait(func() {
+			if ctx.Err() != nil {
+				// a later check, with other settings, has taken over
+				return
+			}
+			s.daemonErr = err
+			switch {
+			case s.running.Load():
+				// the status is the run's
+			case err != nil:
+				s.setStatus(fmt.Sprintf("Docker daemon unreachable, retrying: %v", err), true)
+			default:
+				s.setStatus("Idle, connected to Docker "+version, false)
+				if !s.termBroken.Load() {
+					s.runButton.Enable()
+				}
+			}
+		})
+		if err == nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(daemonRetry):
+		}
+	}
+}
+
+// recheckDaemon starts checkDaemon over, giving up on any check still going.
+// It must be called from the UI goroutine.
+func (s *session) recheckDaemon() {
+	if s.stopCheck != nil {
+		s.stopCheck()
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.stopCheck = cancel
+	s.daemonErr = nil
+	go s.checkDaemon(ctx)
+}
+
+// daemonSettle is how long the daemon's connection settings have to stay the
+// same, e.g. while a host is typed in, before it is checked again with them.
+const daemonSettle = 500 * time.Millisecond
+
+// daemonChanged checks the daemon again, for every session, once its
+// connection settings have settled. It must be called from the UI goroutine.
+func (s *AppState) daemonChanged() {
+	if s.daemonTimer != nil {
+		s.daemonTimer.Stop()
+	}
+	s.daemonTimer = time.AfterFunc(daemonSettle, func() {
+		fyne.Do(func() {
+			for _, sess := range s.sessions {
+				sess.recheckDaemon()
+			}
+		})
+	})
+}
+
+// daemonVersion pings the daemon, returning its version and OS.
+func daemonVersion(ctx context.Context, daemon dockerDaemon) (string, error) {
+	dc, err := newRawDockerClient(daemon)
+	if err != nil {
+		return "", err
+	}
+	defer dc.Close()
+	ctx, cancel := context.WithTimeout(ctx, daemonRetry)
+	defer cancel()
+	v, err := dc.ServerVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s (%s/%s)", v.Version, v.Os, v.Arch), nil
+}
+
+// waitOverlay sits over the terminal from the start of a run until the
+// container writes something, so that a slow start, e.g. while an image is
+// pulled, doesn't look like a hang.
+type waitOverlay struct {
+	bar *widget.ProgressBarInfinite
+	box *fyne.Container
+}
+
+func newWaitOverlay() *waitOverlay {
+	o := &waitOverlay{bar: widget.NewProgressBarInfinite()}
+	o.bar.Stop()
+	label := widget.NewLabelWithStyle("Waiting for the container…", fyne.TextAlignCenter, fyne.TextStyle{})
+	o.box = container.NewCenter(container.NewGridWrap(fyne.NewSize(300, label.MinSize().Height),
+		container.NewStack(o.bar, label)))
+	o.box.Hide()
+	return o
+}
+
+// show and hide may be called from any goroutine.
+func (o *waitOverlay) show() {
+	fyne.Do(func() {
+		o.box.Show()
+		o.bar.Start()
+	})
+}
+
+func (o *waitOverlay) hide() {
+	fyne.Do(func() {
+		o.bar.Stop()
+		o.box.Hide()
+	})
+}
+
+// firstReadNotifier calls onRead, once, as soon as a read from r returns
+// anything.
+type firstReadNotifier struct {
+	r      io.Reader
+	once   sync.Once
+	onRead func()
+}
+
+func (n *firstReadNotifier) Read(p []byte) (int, error) {
+	l, err := n.r.Read(p)
+	if l > 0 {
+		n.once.Do(n.onRead)
+	}
+	return l, err
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"sync/atomic"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/theme"
+	"github.com/fyne-io/terminal"
+)
+
+// bellFlash is how long the terminal flashes for a bell, bells any closer
+// together than that are one flash.
+const bellFlash = 300 * time.Millisecond
+
+// watchTitle follows the title the container sets with OSC 0 or 2, showing
+// it in the session's tab and the window title.
+func (s *session) watchTitle(t *terminal.Terminal) {
+	// a little room, as the terminal drops changes rather than block on us
+	ch := make(chan terminal.Config, 16)
+	t.AddListener(ch)
+	go func() {
+		for cfg := range ch {
+			fyne.Do(func() {
+				// the terminal keeps the last title set after the run is over
+				if s.runTitle == "" || cfg.Title == s.termTitle {
+					return
+				}
+				s.termTitle = cfg.Title
+				s.refreshTitle()
+			})
+		}
+	}()
+}
+
+// setRunTitle describes the running container in the window title, or
+// clears it along with the container's own title once the run is over. It
+// must be called from the UI goroutine.
+func (s *session) setRunTitle(run string) {
+	s.runTitle = run
+	if run == "" {
+		s.termTitle = ""
+	}
+	s.refreshTitle()
+}
+
+// refreshTitle shows the titles in the session's tab, and in the window's
+// title if it is the selected one.
+func (s *session) refreshTitle() {
+	tab := s.name
+	if s.runTitle != "" {
+		tab = s.runTitle
+	}
+	if s.termTitle != "" {
+		tab = s.termTitle
+	}
+	if s.tab.Text != tab {
+		s.tab.Text = tab
+		s.tabs.Refresh()
+	}
+	if s.current() != s {
+		return
+	}
+	title := appTitle
+	if s.runTitle != "" {
+		title += " — " + s.runTitle
+	}
+	if s.termTitle != "" {
+		title = fmt.Sprintf("%s — %s", s.termTitle, title)
+	}
+	s.mainWindow.SetTitle(title)
+}
+
+// bellWatcher calls ring for each BEL written to it, other than those ending
+// an OSC sequence.
+type bellWatcher struct {
+	scanner ansiScanner
+	ring    func()
+}
+
+func (b *bellWatcher) Write(p []byte) (int, error) {
+	b.scanner.scan(p, func(kind tokenKind, tok []byte) {
+		if kind == tokenControl && tok[0] == '\a' {
+			b.ring()
+		}
+	})
+	return len(p), nil
+}
+
+// bellOverlay flashes over the terminal when the container rings the bell,
+// as there is no portable way to make a sound or flash the window itself.
+type bellOverlay struct {
+	rect    *canvas.Rectangle
+	ringing atomic.Bool
+}
+
+func newBellOverlay() *bellOverlay {
+	b := &bellOverlay{rect: canvas.NewRectangle(color.Transparent)}
+	b.rect.Hide()
+	return b
+}
+
+// ring flashes the overlay, it may be called from any goroutine.
+func (b *bellOverlay) ring() {
+	if !b.ringing.CompareAndSwap(false, true) {
+		return
+	}
+	fyne.Do(func() {
+		r, g, bl, _ := theme.Color(theme.ColorNameWarning).RGBA()
+		start := color.NRGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(bl >> 8), A: 0x60}
+		end := start
+		end.A = 0
+		b.rect.Show()
+		anim := canvas.NewColorRGBAAnimation(start, end, bellFlash, func(c color.Color) {
+			b.rect.FillColor = c
+			b.rect.Refresh()
+		})
+		anim.Start()
+	})
+	time.AfterFunc(bellFlash, func() {
+		fyne.Do(b.rect.Hide)
+		b.ringing.Store(false)
+	})
+}
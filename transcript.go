@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+// transcript records the raw output of the current run, escape sequences and
+// all, to a temporary file so that it can be saved in full however long it
+// gets.
+type transcript struct {
+	mu     sync.Mutex
+	f      *os.File
+	failed bool
+}
+
+// reset discards the previous run's output and starts recording anew.
+func (t *transcript) reset() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closeLocked()
+	f, err := os.CreateTemp("", "fyne-terminal-slow-*.log")
+	if err != nil {
+		return err
+	}
+	t.f, t.failed = f, false
+	return nil
+}
+
+// Write never fails, running out of space for the transcript shouldn't stop
+// the run.
+func (t *transcript) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.f == nil || t.failed {
+		return len(p), nil
+	}
+	if _, err := t.f.Write(p); err != nil {
+		fyne.LogError("unable to record output, the transcript will be incomplete", err)
+		t.failed = true
+	}
+	return len(p), nil
+}
+
+// save copies everything recorded so far to w, with the escape sequences
+// removed if strip is set.
+func (t *transcript) save(w io.Writer, strip bool) error {
+	t.mu.Lock()
+	if t.f == nil {
+		t.mu.Unlock()
+		return nil
+	}
+	info, err := t.f.Stat()
+	if err != nil {
+		t.mu.Unlock()
+		return err
+	}
+	// the run may well carry on writing while this is read back
+	r := io.NewSectionReader(t.f, 0, info.Size())
+	t.mu.Unlock()
+	if strip {
+		s := &ansiStripper{w: w}
+		if _, err := io.Copy(s, r); err != nil {
+			return err
+		}
+		return s.Flush()
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// close removes the transcript, as the app exits.
+func (t *transcript) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closeLocked()
+}
+
+func (t *transcript) closeLocked() {
+	if t.f == nil {
+		return
+	}
+	_ = t.f.Close()
+	_ = os.Remove(t.f.Name())
+	t.f = nil
+}
+
+// ansiStripper passes on what is written to it without its escape sequences.
+type ansiStripper struct {
+	w       io.Writer
+	scanner ansiScanner
+	out     []byte
+}
+
+func (s *ansiStripper) Write(p []byte) (int, error) {
+	s.out = s.out[:0]
+	s.scanner.scan(p, s.writeToken)
+	if _, err := s.w.Write(s.out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush passes on anything held back waiting for the rest of a sequence.
+func (s *ansiStripper) Flush() error {
+	s.out = s.out[:0]
+	s.scanner.flush(s.writeToken)
+	_, err := s.w.Write(s.out)
+	return err
+}
+
+func (s *ansiStripper) writeToken(kind tokenKind, tok []byte) {
+	if kind != tokenEscape {
+		s.out = append(s.out, tok...)
+	}
+}
+
+// saveOutput asks where to save the current run's output, either raw, to be
+// replayed with cat, or as plain text.
+func (s *session) saveOutput() {
+	strip := widget.NewCheck("Strip escape sequences, for a plain text log", nil)
+	dialog.ShowCustomConfirm("Save Output", "Save…", "Cancel", strip, func(ok bool) {
+		if !ok {
+			return
+		}
+		d := dialog.NewFileSave(func(w fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, s.mainWindow)
+				return
+			}
+			if w == nil {
+				return // cancelled
+			}
+			// it may be large, keep the copy off the UI goroutine
+			go func() {
+				err := s.transcript.save(w, strip.Checked)
+				if cErr := w.Close(); err == nil {
+					err = cErr
+				}
+				if err != nil {
+					fyne.Do(func() {
+						dialog.ShowError(fmt.Errorf("unable to save output: %w", err), s.mainWindow)
+					})
+				}
+			}()
+		}, s.mainWindow)
+		if strip.Checked {
+			d.SetFileName("output.txt")
+		} else {
+			d.SetFileName("output.log")
+		}
+		d.SetFilter(storage.NewExtensionFileFilter([]string{".txt", ".log"}))
+		d.Show()
+	}, s.mainWindow)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	prefMaxLineLength = "output.maxLineLength"
+	prefPromptPattern = "output.promptPattern"
+
+	truncatedMarker = "…(truncated)"
+)
+
+// lineTruncator cuts lines written to the terminal off after max columns,
+// marking where it did so, as a single huge line (e.g. minified logs) is very
+// slow for the terminal widget to handle.
+//
+// Escape sequences are always passed on whole, whether or not the text around
+// them was cut, so colours etc are still applied and reset as they should be.
+type lineTruncator struct {
+	w       io.Writer
+	max     int
+	col     int
+	cut     bool
+	scanner ansiScanner
+	out     []byte
+}
+
+func newLineTruncator(w io.Writer, max int) *lineTruncator {
+	return &lineTruncator{w: w, max: max}
+}
+
+func (t *lineTruncator) Write(p []byte) (int, error) {
+	t.out = t.out[:0]
+	t.scanner.scan(p, t.writeToken)
+	if _, err := t.w.Write(t.out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush passes on anything held back waiting for the rest of a sequence.
+func (t *lineTruncator) Flush() error {
+	t.out = t.out[:0]
+	t.scanner.flush(t.writeToken)
+	if len(t.out) == 0 {
+		return nil
+	}
+	_, err := t.w.Write(t.out)
+	return err
+}
+
+func (t *lineTruncator) writeToken(kind tokenKind, tok []byte) {
+	switch kind {
+	case tokenEscape:
+		t.out = append(t.out, tok...)
+	case tokenControl:
+		switch tok[0] {
+		case '\r', '\n':
+			t.col, t.cut = 0, false
+		case '\b':
+			if t.col > 0 && !t.cut {
+				t.col--
+			}
+		case '\t':
+			t.col += 8 - t.col%8
+		}
+		t.out = append(t.out, tok...)
+	case tokenText:
+		if t.cut {
+			return
+		}
+		for len(tok) > 0 {
+			if t.col >= t.max {
+				t.out = append(t.out, truncatedMarker...)
+				t.cut = true
+				return
+			}
+			_, size := utf8.DecodeRune(tok)
+			t.out = append(t.out, tok[:size]...)
+			tok = tok[size:]
+			t.col++
+		}
+	}
+}
+
+// maxLineLength is the configured line length limit, 0 if lines aren't cut.
+func maxLineLength(prefs fyne.Preferences) int {
+	return prefs.Int(prefMaxLineLength)
+}
+
+// promptPattern is the configured regular expression for prompt lines, nil
+// if there isn't one or it doesn't compile.
+func promptPattern(prefs fyne.Preferences) *regexp.Regexp {
+	p := prefs.String(prefPromptPattern)
+	if p == "" {
+		return nil
+	}
+	re, err := regexp.Compile(p)
+	if err != nil {
+		fyne.LogError("ignoring invalid prompt pattern", err)
+		return nil
+	}
+	return re
+}
+
+// showOutputSettings lets the user set how the terminal treats very long
+// lines, batches output, falls behind and what it leaves out, how prompts are
+// found in the scrollback and how much it keeps.
+func (s *AppState) showOutputSettings() {
+	prefs := s.app.Preferences()
+	maxLen := widget.NewEntry()
+	maxLen.SetPlaceHolder("columns, lines are never cut if empty")
+	if n := maxLineLength(prefs); n > 0 {
+		maxLen.SetText(strconv.Itoa(n))
+	}
+	maxLen.Validator = optional(func(s string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		if n < 1 {
+			return errors.New("must be at least 1")
+		}
+		return nil
+	})
+	batch := widget.NewEntry()
+	batch.SetText(strconv.Itoa(int(outputBatch(prefs) / time.Millisecond)))
+	batch.Validator = func(s string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		if n < 0 || n > maxOutputBatch {
+			return fmt.Errorf("must be from 0 to %d", maxOutputBatch)
+		}
+		return nil
+	}
+	mode, waiting := backlogSettings(prefs)
+	labels := make([]string, len(backlogModes))
+	for i, m := range backlogModes {
+		labels[i] = m.label
+	}
+	backlog := widget.NewSelect(labels, nil)
+	for i, m := range backlogModes {
+		if m.mode == mode {
+			backlog.SetSelectedIndex(i)
+		}
+	}
+	backlogLines := widget.NewEntry()
+	backlogLines.SetText(strconv.Itoa(waiting))
+	backlogLines.Validator = func(s string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		if n < minBacklogLines {
+			return fmt.Errorf("must be at least %d", minBacklogLines)
+		}
+		return nil
+	}
+	dropper := widget.NewCheck("Leave out lines that would scroll straight off", nil)
+	dropper.SetChecked(dropOffscreen(prefs))
+	prompt := widget.NewEntry()
+	prompt.SetPlaceHolder(`e.g. ^\$ or ^root@, OSC 133 marks only if empty`)
+	prompt.SetText(prefs.String(prefPromptPattern))
+	prompt.Validator = optional(func(s string) error {
+		_, err := regexp.Compile(s)
+		return err
+	})
+	lines := widget.NewEntry()
+	lines.SetText(strconv.Itoa(scrollbackLines(prefs)))
+	lines.Validator = func(s string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		if n < minScrollbackLines {
+			return fmt.Errorf("must be at least %d", minScrollbackLines)
+		}
+		return nil
+	}
+	d := dialog.NewForm("Output Settings", "Save", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Maximum line length", maxLen),
+		widget.NewFormItem("Batch output for (ms)", batch),
+		widget.NewFormItem("When the terminal falls behind", backlog),
+		widget.NewFormItem("Skip past (lines waiting)", backlogLines),
+		widget.NewFormItem("", dropper),
+		widget.NewFormItem("Prompt pattern", prompt),
+		widget.NewFormItem("Scrollback lines", lines),
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+		prefs.SetString(prefPromptPattern, prompt.Text)
+		// the form won't submit unless it validates
+		n, _ := strconv.Atoi(strings.TrimSpace(lines.Text))
+		prefs.SetInt(prefScrollbackLines, n)
+		// applied from the next run
+		ms, _ := strconv.Atoi(strings.TrimSpace(batch.Text))
+		prefs.SetInt(prefOutputBatch, ms)
+		prefs.SetString(prefBacklogMode, backlogModes[backlog.SelectedIndex()].mode)
+		waiting, _ := strconv.Atoi(strings.TrimSpace(backlogLines.Text))
+		prefs.SetInt(prefBacklogLines, waiting)
+		prefs.SetBool(prefDropOffscreen, dropper.Checked)
+		for _, sess := range s.sessions {
+			sess.scrollback.setMaxLines(n)
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(maxLen.Text)); err == nil {
+			prefs.SetInt(prefMaxLineLength, n)
+		} else {
+			prefs.RemoveValue(prefMaxLineLength)
+		}
+	}, s.mainWindow)
+	d.Resize(fyne.NewSize(400, 0))
+	d.Show()
+}
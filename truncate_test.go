@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// truncate writes chunks through a lineTruncator cutting lines at max
+// columns, returning what it passed on.
+func truncate(t *testing.T, max int, chunks ...[]byte) string {
+	t.Helper()
+	var out bytes.Buffer
+	tr := newLineTruncator(&out, max)
+	for _, c := range chunks {
+		if _, err := tr.Write(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestLineTruncator(t *testing.T) {
+	in := "日本語のテキスト\x1b[31m red\x1b[0m\r\nshort\r\n"
+	want := "日本語のテ" + truncatedMarker + "\x1b[31m\x1b[0m\r\nshort\r\n"
+	if got := truncate(t, 5, []byte(in)); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := truncate(t, 1000, []byte(in)); got != in {
+		t.Errorf("short lines give %q, want them as they were", got)
+	}
+}
+
+func TestLineTruncatorSplit(t *testing.T) {
+	in := []byte(splitSample + splitSample)
+	for _, max := range []int{3, 8, 1000} {
+		want := truncate(t, max, in)
+		for i := range len(in) + 1 {
+			if got := truncate(t, max, bytes.Clone(in[:i]), bytes.Clone(in[i:])); got != want {
+				t.Errorf("max %d split at %d gives %q, want %q", max, i, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// viewerQueue is how many writes a viewer may fall behind by before it is
+// disconnected, so that a slow browser never holds up the local terminal.
+const viewerQueue = 256
+
+// viewerHub broadcasts the session output to the browsers connected to it.
+type viewerHub struct {
+	mu      sync.Mutex
+	viewers map[chan []byte]struct{}
+}
+
+func newViewerHub() *viewerHub {
+	return &viewerHub{viewers: map[chan []byte]struct{}{}}
+}
+
+// Write queues a copy of p for every viewer, dropping any that can't keep up.
+func (h *viewerHub) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.viewers) == 0 {
+		return len(p), nil
+	}
+	buf := append([]byte(nil), p...)
+	for ch := range h.viewers {
+		select {
+		case ch <- buf:
+		default:
+			delete(h.viewers, ch)
+			close(ch)
+		}
+	}
+	return len(p), nil
+}
+
+func (h *viewerHub) add() chan []byte {
+	ch := make(chan []byte, viewerQueue)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.viewers[ch] = struct{}{}
+	return ch
+}
+
+func (h *viewerHub) remove(ch chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.viewers[ch]; ok {
+		delete(h.viewers, ch)
+		close(ch)
+	}
+}
+
+// serve streams output to each viewer over a WebSocket. Only viewers that
+// give token, in the URL, may type: anything they send is passed to input,
+// while what others send is ignored. Connections from pages elsewhere, which
+// a browser would otherwise let any site make, are refused.
+func (h *viewerHub) serve(token string, input func([]byte)) websocket.Server {
+	return websocket.Server{Handshake: sameOrigin, Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		ws.PayloadType = websocket.BinaryFrame
+		given := ws.Request().URL.Query().Get("token")
+		canType := subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+		ch := h.add()
+		defer h.remove(ch)
+		go func() {
+			// the read fails once the connection is closed, ending this
+			for {
+				var msg []byte
+				if err := websocket.Message.Receive(ws, &msg); err != nil {
+					return
+				}
+				if canType {
+					input(msg)
+				}
+			}
+		}()
+		for buf := range ch {
+			if _, err := ws.Write(buf); err != nil {
+				return
+			}
+		}
+		// the queue was closed as it fell too far behind
+		_, _ = io.WriteString(ws, "\r\n[disconnected, not keeping up with the output]\r\n")
+	}}
+}
+
+// sameOrigin is a websocket handshake that only accepts connections from the
+// viewer page itself, whose origin is the server it was loaded from.
+func sameOrigin(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	if origin == nil {
+		return errors.New("no origin")
+	}
+	if !strings.EqualFold(origin.Host, r.Host) {
+		return fmt.Errorf("origin %s is not this server", origin)
+	}
+	config.Origin = origin
+	return nil
+}
+
+// serveViewers runs the viewer web server on addr until ctx is done.
+func (s *session) serveViewers(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = io.WriteString(w, viewerPage)
+	})
+	// a new one every launch, so only those given the URL can type
+	token := rand.Text()
+	mux.Handle("/ws", s.viewers.serve(token, func(b []byte) {
+		// the same as typing it into the terminal
+		_, _ = s.terminal.Write(b)
+	}))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("unable to serve viewers: %w", err)
+	}
+	url := fmt.Sprintf("http://%s/?token=%s", ln.Addr(), token)
+	lifecycleLog.Info("serving viewers", "url", url)
+	s.setStatus("Serving the terminal to browsers at "+url, false)
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// viewerPage is a minimal xterm.js client for the /ws endpoint.
+const viewerPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Slow Terminal Demo</title>
+<link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/@xterm/xterm@5.5.0/css/xterm.css">
+<script src="https://cdn.jsdelivr.net/npm/@xterm/xterm@5.5.0/lib/xterm.js"></script>
+<style>html, body, #term { margin: 0; height: 100%; background: #000; }</style>
+</head>
+<body>
+<div id="term"></div>
+<script>
+const term = new Terminal({convertEol: false});
+term.open(document.getElementById("term"));
+const ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/ws" + location.search);
+ws.binaryType = "arraybuffer";
+ws.onmessage = (e) => term.write(new Uint8Array(e.data));
+ws.onclose = () => term.write("\r\n[connection closed]\r\n");
+const enc = new TextEncoder();
+term.onData((d) => ws.readyState === WebSocket.OPEN && ws.send(enc.encode(d)));
+</script>
+</body>
+</html>
+`
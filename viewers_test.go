@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// TestViewerHubServe checks that only the viewer page, given the token, can
+// type into the terminal, and that other sites can't connect at all.
+func TestViewerHubServe(t *testing.T) {
+	const token = "secret"
+	hub := newViewerHub()
+	typed := make(chan string, 10)
+	srv := httptest.NewServer(hub.serve(token, func(b []byte) { typed <- string(b) }))
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	dial := func(query, origin string) (*websocket.Conn, error) {
+		return websocket.Dial(wsURL+query, "", origin)
+	}
+	for _, c := range []struct {
+		name, query string
+		canType     bool
+	}{
+		{"with the token", "?token=" + token, true},
+		{"without the token", "", false},
+		{"with the wrong token", "?token=guess", false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			ws, err := dial(c.query, srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ws.Close()
+			if err := websocket.Message.Send(ws, []byte("ls\r")); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case got := <-typed:
+				if !c.canType {
+					t.Errorf("typed %q", got)
+				}
+			case <-time.After(200 * time.Millisecond):
+				if c.canType {
+					t.Error("nothing typed")
+				}
+			}
+			// the output is still shown either way, once the viewer is added
+			var got []byte
+			for range 50 {
+				_, _ = hub.Write([]byte("hello"))
+				_ = ws.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+				if err := websocket.Message.Receive(ws, &got); err == nil {
+					break
+				}
+			}
+			if string(got) != "hello" {
+				t.Errorf("received %q, want hello", got)
+			}
+		})
+	}
+
+	if ws, err := dial("?token="+token, "http://evil.example"); err == nil {
+		ws.Close()
+		t.Error("connected from another site")
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !ci && !wasm && !js && !android && !ios
+
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// watchMinimised calls onChange, on the UI goroutine, whenever w is
+// minimised or restored. Fyne has no event for this, only for focus, so it is
+// taken from the GLFW window behind w. A window without one, such as the
+// test driver's, is never reported minimised, as on builds without GLFW (see
+// visibility_noglfw.go). It must be called from the UI goroutine once w has
+// been shown.
+func watchMinimised(w fyne.Window, onChange func(minimised bool)) {
+	gl, ok := w.(interface{ RunWithContext(func()) })
+	if !ok {
+		return
+	}
+	gl.RunWithContext(func() {
+		if view := glfw.GetCurrentContext(); view != nil {
+			view.SetIconifyCallback(func(_ *glfw.Window, iconified bool) { onChange(iconified) })
+		}
+	})
+}
@@ -0,0 +1,9 @@
+//go:build ci || wasm || js || android || ios
+
+package main
+
+import "fyne.io/fyne/v2"
+
+// watchMinimised does nothing where Fyne doesn't use GLFW, so the window is
+// never reported minimised.
+func watchMinimised(fyne.Window, func(minimised bool)) {}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	cerrdefs "github.com/containerd/errdefs"
+	dockerContainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+)
+
+// volumeNamePattern is what the daemon accepts for a local volume name.
+var volumeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+func validateVolumeName(name string) error {
+	if !volumeNamePattern.MatchString(name) {
+		return fmt.Errorf("%q is not a valid volume name, use at least two of [a-zA-Z0-9_.-] not starting with punctuation", name)
+	}
+	return nil
+}
+
+// parseVolumes parses lines of the form NAME:PATH[:ro] into named volume
+// mounts.
+func parseVolumes(text string) ([]mount.Mount, error) {
+	var mounts []mount.Mount
+	for _, line := range nonEmptyLines(text) {
+		parts := strings.Split(line, ":")
+		if len(parts) < 2 || len(parts) > 3 || len(parts) == 3 && parts[2] != "ro" && parts[2] != "rw" {
+			return nil, fmt.Errorf("%q is not of the form NAME:PATH[:ro]", line)
+		}
+		if err := validateVolumeName(parts[0]); err != nil {
+			return nil, err
+		}
+		if !path.IsAbs(parts[1]) {
+			return nil, fmt.Errorf("mount point %q for volume %s is not absolute", parts[1], parts[0])
+		}
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeVolume,
+			Source:   parts[0],
+			Target:   parts[1],
+			ReadOnly: len(parts) == 3 && parts[2] == "ro",
+		})
+	}
+	return mounts, nil
+}
+
+// parseTmpfs parses lines of the form PATH[:OPTIONS] into tmpfs mount points
+// and their options, as docker run's --tmpfs takes.
+func parseTmpfs(text string) (map[string]string, error) {
+	var tmpfs map[string]string
+	for _, line := range nonEmptyLines(text) {
+		target, options, _ := strings.Cut(line, ":")
+		if !path.IsAbs(target) {
+			return nil, fmt.Errorf("tmpfs mount point %q is not absolute", target)
+		}
+		if tmpfs == nil {
+			tmpfs = map[string]string{}
+		}
+		tmpfs[target] = options
+	}
+	return tmpfs, nil
+}
+
+// ensureVolumes checks each named volume exists, asking whether to create the
+// ones that don't rather than letting the daemon create them silently.
+func ensureVolumes(ctx context.Context, dc dockerClient, mounts []mount.Mount, confirmCreate func(name string) bool) error {
+	for _, m := range mounts {
+		if m.Type != mount.TypeVolume || m.Source == "" {
+			// anonymous volumes are always created
+			continue
+		}
+		_, err := dc.VolumeInspect(ctx, m.Source)
+		if err == nil {
+			continue
+		}
+		if !cerrdefs.IsNotFound(err) {
+			return fmt.Errorf("unable to inspect volume %s: %w", m.Source, err)
+		}
+		if !confirmCreate(m.Source) {
+			return fmt.Errorf("volume %s does not exist", m.Source)
+		}
+		if _, err := dc.VolumeCreate(ctx, volume.CreateOptions{Name: m.Source}); err != nil {
+			return fmt.Errorf("unable to create volume %s: %w", m.Source, err)
+		}
+	}
+	return nil
+}
+
+// volumeLine is how a volume picked in the UI is added to the volumes entry,
+// mounted under /mnt by default.
+func volumeLine(name string) string {
+	return name + ":/mnt/" + name
+}
+
+// appendLine adds line to the end of a multi-line entry.
+func appendLine(e *widget.Entry, line string) {
+	text := strings.TrimRight(e.Text, "\n")
+	if text != "" {
+		text += "\n"
+	}
+	e.SetText(text + line)
+}
+
+// pickVolume lists the daemon's volumes and lets the user choose one to mount.
+func pickVolume(parent fyne.Window, daemon dockerDaemon, picked func(name string)) {
+	go func() {
+		names, err := listVolumes(daemon)
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(err, parent)
+				return
+			}
+			if len(names) == 0 {
+				dialog.ShowInformation("No volumes", "There are no named volumes, create one with New Volume.", parent)
+				return
+			}
+			sel := widget.NewSelect(names, nil)
+			sel.SetSelectedIndex(0)
+			dialog.ShowCustomConfirm("Mount Volume", "Add", "Cancel", sel, func(ok bool) {
+				if ok && sel.Selected != "" {
+					picked(sel.Selected)
+				}
+			}, parent)
+		})
+	}()
+}
+
+func listVolumes(daemon dockerDaemon) ([]string, error) {
+	dc, err := newRawDockerClient(daemon)
+	if err != nil {
+		return nil, err
+	}
+	defer dc.Close()
+	resp, err := dc.VolumeList(context.Background(), volume.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list volumes: %w", err)
+	}
+	names := make([]string, 0, len(resp.Volumes))
+	for _, v := range resp.Volumes {
+		names = append(names, v.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// createVolume asks for a name and creates a new named volume with it.
+func createVolume(parent fyne.Window, daemon dockerDaemon, created func(name string)) {
+	name := widget.NewEntry()
+	name.Validator = validateVolumeName
+	dialog.ShowForm("New Volume", "Create", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Name", name)},
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			n := name.Text
+			go func() {
+				err := func() error {
+					dc, err := newRawDockerClient(daemon)
+					if err != nil {
+						return err
+					}
+					defer dc.Close()
+					_, err = dc.VolumeCreate(context.Background(), volume.CreateOptions{Name: n})
+					return err
+				}()
+				fyne.Do(func() {
+					if err != nil {
+						dialog.ShowError(fmt.Errorf("unable to create volume %s: %w", n, err), parent)
+						return
+					}
+					created(n)
+				})
+			}()
+		}, parent)
+}
+
+// printVolumeMounts reports where each named volume ended up, as the daemon
+// resolved it.
+func printVolumeMounts(w io.Writer, mounts []dockerContainer.MountPoint) {
+	for _, m := range mounts {
+		if m.Type != mount.TypeVolume {
+			continue
+		}
+		mode := "rw"
+		if !m.RW {
+			mode = "ro"
+		}
+		_, _ = fmt.Fprintf(w, "Volume %s mounted at %s (%s)\r\n", m.Name, m.Destination, mode)
+	}
+}